@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/christophrj/openmcp-testing/pkg/providers"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
 	"github.com/christophrj/openmcp-testing/pkg/setup"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/e2e-framework/klient/wait"
@@ -29,8 +30,9 @@ func TestMain(m *testing.M) {
 		},
 		ClusterProviders: []providers.ClusterProviderSetup{
 			{
-				Name:  "kind",
-				Image: "ghcr.io/openmcp-project/images/cluster-provider-kind:v0.0.15",
+				Name:              "kind",
+				Image:             "ghcr.io/openmcp-project/images/cluster-provider-kind:v0.0.15",
+				MountDockerSocket: true,
 				Opts: []wait.Option{
 					wait.WithTimeout(time.Minute),
 				},
@@ -50,6 +52,8 @@ func TestMain(m *testing.M) {
 	if err := openmcp.Bootstrap(testenv); err != nil {
 		panic(fmt.Errorf("openmcp bootstrap failed: %v", err))
 	}
+	testenv.BeforeEachFeature(resources.TrackFeatureObjects(), resources.AnnotateFeatureObjects()).
+		AfterEachFeature(resources.DeleteFeatureObjects(wait.WithTimeout(time.Minute)))
 	os.Exit(testenv.Run(m))
 }
 
@@ -1,11 +1,6 @@
 package internal
 
 import (
-	"io"
-	"os"
-	"strings"
-	"text/template"
-
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -13,32 +8,6 @@ import (
 	"sigs.k8s.io/e2e-framework/klient/k8s"
 )
 
-// ExecTemplate parses and executes textTemplate with the provided data
-func ExecTemplate(textTemplate string, data interface{}) (string, error) {
-	tmpl, err := template.New("t").Parse(textTemplate)
-	if err != nil {
-		return "", err
-	}
-	result := strings.Builder{}
-	if err := tmpl.Execute(&result, data); err != nil {
-		return "", err
-	}
-	return result.String(), nil
-}
-
-// ExecTemplateFile parses and executes a template referenced by a file with the provided data
-func ExecTemplateFile(filePath string, data interface{}) (string, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-	bytes, err := io.ReadAll(f)
-	if err != nil {
-		return "", err
-	}
-	return ExecTemplate(string(bytes), data)
-}
-
 // ToUnstructured converts a Object to Unstructured
 func ToUnstructured(obj k8s.Object) (*unstructured.Unstructured, error) {
 	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
@@ -66,3 +35,20 @@ func UnstructuredRef(name string, namespace string, gvk schema.GroupVersionKind)
 	obj.SetGroupVersionKind(gvk)
 	return obj
 }
+
+// DeepMerge merges src into dst. Nested maps are merged, all other values replace the existing value and nil values remove the key
+func DeepMerge(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			DeepMerge(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
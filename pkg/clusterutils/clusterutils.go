@@ -2,13 +2,16 @@ package clusterutils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/christophrj/openmcp-testing/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/e2e-framework/klient"
 	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
@@ -20,11 +23,17 @@ import (
 // a klient that is set up to interact with the cluster identified by the passed
 // in cluster name prefix
 func ConfigByPrefix(prefix string, namespace string) (*envconf.Config, error) {
-	kind := cluster.NewProvider()
 	clusterName, err := retrieveKindClusterNameByPrefix(prefix)
 	if err != nil {
 		return nil, err
 	}
+	return ConfigByName(clusterName, namespace)
+}
+
+// ConfigByName returns an environment Config with the passed in namespace and
+// a klient that is set up to interact with the kind cluster of exactly the passed in name
+func ConfigByName(clusterName string, namespace string) (*envconf.Config, error) {
+	kind := cluster.NewProvider()
 	kubeConfig, err := kind.KubeConfig(clusterName, false)
 	if err != nil {
 		return nil, err
@@ -44,7 +53,13 @@ func ConfigByPrefix(prefix string, namespace string) (*envconf.Config, error) {
 // with the onboarding cluster and default namespace
 // In scenarios where you work with multiple onboarding clusters, use ConfigByPrefix instead
 func OnboardingConfig() (*envconf.Config, error) {
-	return ConfigByPrefix("onboarding", corev1.NamespaceDefault)
+	return OnboardingConfigInNamespace(corev1.NamespaceDefault)
+}
+
+// OnboardingConfigInNamespace returns an environment config to work with the passed in namespace of the onboarding cluster,
+// e.g. a project or workspace namespace
+func OnboardingConfigInNamespace(namespace string) (*envconf.Config, error) {
+	return ConfigByPrefix("onboarding", namespace)
 }
 
 // McpConfig is a utility function to return an environment config to work
@@ -54,6 +69,21 @@ func McpConfig() (*envconf.Config, error) {
 	return ConfigByPrefix("mcp", corev1.NamespaceDefault)
 }
 
+// Impersonate returns a config for the same cluster and namespace whose client acts as the passed in user and groups,
+// e.g. to test the permissions of the members of a project
+func Impersonate(c *envconf.Config, user string, groups ...string) (*envconf.Config, error) {
+	restConfig := rest.CopyConfig(c.Client().RESTConfig())
+	restConfig.Impersonate = rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	}
+	client, err := klient.New(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return envconf.New().WithClient(client).WithNamespace(c.Namespace()), nil
+}
+
 func retrieveKindClusterNameByPrefix(prefix string) (string, error) {
 	kind := cluster.NewProvider()
 	clusters, err := kind.List()
@@ -68,13 +98,62 @@ func retrieveKindClusterNameByPrefix(prefix string) (string, error) {
 	return "", fmt.Errorf("no cluster found with prefix %s", prefix)
 }
 
-// ImportToOnboardingCluster applies a set of resources from a directory to the onboarding cluster
+// SweepRun deletes all objects that have been created by the test run with the passed in run ID from all kind clusters
+func SweepRun(ctx context.Context, runID string, options ...wait.Option) error {
+	kind := cluster.NewProvider()
+	clusters, err := kind.List()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, clusterName := range clusters {
+		c, err := ConfigByName(clusterName, corev1.NamespaceDefault)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to retrieve config of cluster %s: %w", clusterName, err))
+			continue
+		}
+		klog.Infof("sweep run %s on cluster %s", runID, clusterName)
+		if err := resources.SweepRun(ctx, c, runID, options...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sweep cluster %s: %w", clusterName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ImportToCluster applies a set of resources from a directory to the cluster of the passed in config,
+// e.g. to one of several MCP clusters
+func ImportToCluster(ctx context.Context, c *envconf.Config, dir string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	return importFromDir(ctx, c, dir, nil, options...)
+}
+
+// ImportToOnboardingCluster applies a set of resources from a directory to the onboarding cluster.
+// Objects are applied server-side, so existing objects converge to the manifests on disk
 func ImportToOnboardingCluster(ctx context.Context, dir string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
 	c, err := OnboardingConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve onboarding cluster config: %v", err)
 	}
-	return importFromDir(ctx, c, dir, options...)
+	return importFromDir(ctx, c, dir, nil, options...)
+}
+
+// ImportTemplatesToOnboardingCluster renders each file of a directory as a template with the passed in data
+// and applies the resulting resources to the onboarding cluster
+func ImportTemplatesToOnboardingCluster(ctx context.Context, dir string, data interface{}, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	c, err := OnboardingConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve onboarding cluster config: %v", err)
+	}
+	return importFromDir(ctx, c, dir, []resources.Option{resources.Template(data)}, options...)
+}
+
+// ImportKustomizationToOnboardingCluster builds the kustomization at the passed in path
+// and applies the resulting resources to the onboarding cluster
+func ImportKustomizationToOnboardingCluster(ctx context.Context, path string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	c, err := OnboardingConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve onboarding cluster config: %v", err)
+	}
+	return importKustomization(ctx, c, path, options...)
 }
 
 // ImportToMcpCluster applies a set of resources from a directory to the mcp cluster
@@ -83,18 +162,56 @@ func ImportToMcpCluster(ctx context.Context, dir string, options ...wait.Option)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve mcp cluster config: %v", err)
 	}
-	return importFromDir(ctx, c, dir, options...)
+	return importFromDir(ctx, c, dir, nil, options...)
 }
 
-func importFromDir(ctx context.Context, c *envconf.Config, dir string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
-	objList, err := resources.CreateObjectsFromDir(ctx, c, dir)
+// ImportTemplatesToMcpCluster renders each file of a directory as a template with the passed in data
+// and applies the resulting resources to the mcp cluster
+func ImportTemplatesToMcpCluster(ctx context.Context, dir string, data interface{}, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	c, err := McpConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create objects from %s: %v", dir, err)
+		return nil, fmt.Errorf("failed to retrieve mcp cluster config: %v", err)
+	}
+	return importFromDir(ctx, c, dir, []resources.Option{resources.Template(data)}, options...)
+}
+
+// ImportKustomizationToMcpCluster builds the kustomization at the passed in path
+// and applies the resulting resources to the mcp cluster
+func ImportKustomizationToMcpCluster(ctx context.Context, path string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	c, err := McpConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve mcp cluster config: %v", err)
 	}
+	return importKustomization(ctx, c, path, options...)
+}
+
+func importFromDir(ctx context.Context, c *envconf.Config, dir string, opts []resources.Option, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	objList, err := resources.CreateObjectsFromDir(ctx, c, dir, append(importOptions(options...), opts...)...)
+	if err != nil {
+		return objList, fmt.Errorf("failed to create objects from %s: %v", dir, err)
+	}
+	return objList, waitForImport(c, objList, options...)
+}
+
+func importKustomization(ctx context.Context, c *envconf.Config, path string, options ...wait.Option) (*unstructured.UnstructuredList, error) {
+	objList, err := resources.CreateObjectsFromKustomization(ctx, c, path, importOptions(options...)...)
+	if err != nil {
+		return objList, fmt.Errorf("failed to create objects from kustomization %s: %v", path, err)
+	}
+	return objList, waitForImport(c, objList, options...)
+}
+
+func importOptions(options ...wait.Option) []resources.Option {
+	return []resources.Option{
+		resources.ServerSideApply(resources.DefaultFieldManager),
+		resources.ForceConflicts(),
+		resources.WaitOptions(options...),
+	}
+}
+
+func waitForImport(c *envconf.Config, objList *unstructured.UnstructuredList, options ...wait.Option) error {
 	if options != nil {
-		if err := wait.For(conditions.New(c.Client().Resources()).ResourcesFound(objList), options...); err != nil {
-			return nil, err
-		}
+		return wait.For(conditions.New(c.Client().Resources()).ResourcesFound(objList), options...)
 	}
-	return objList, nil
+	return nil
 }
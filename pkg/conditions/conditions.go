@@ -50,6 +50,89 @@ func Status(obj k8s.Object, cfg *envconf.Config, key string, value string) wait.
 	}
 }
 
+// StatusSet returns true if the status key of an object is set to a non-empty value.
+// If an object is not found, the condition is not satisfied and no error is returned.
+func StatusSet(obj k8s.Object, cfg *envconf.Config, key string) wait.ConditionWithContextFunc {
+	return func(ctx context.Context) (done bool, err error) {
+		klog.Infof("%s: waiting for status %s to be set", fmtObj(obj), key)
+		err = cfg.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj)
+		if err != nil {
+			return false, internal.IgnoreNotFound(err)
+		}
+		u, err := internal.ToUnstructured(obj)
+		if err != nil {
+			return false, err
+		}
+		value, found, err := unstructured.NestedFieldNoCopy(u.Object, "status", key)
+		if err != nil || !found {
+			return false, err
+		}
+		return value != nil && value != "", nil
+	}
+}
+
+// ObservedGeneration returns true if the status.observedGeneration of an object is at least the passed in generation.
+// If an object is not found, the condition is not satisfied and no error is returned.
+func ObservedGeneration(obj k8s.Object, cfg *envconf.Config, generation int64) wait.ConditionWithContextFunc {
+	return func(ctx context.Context) (done bool, err error) {
+		klog.Infof("%s: waiting for observed generation %d", fmtObj(obj), generation)
+		err = cfg.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj)
+		if err != nil {
+			return false, internal.IgnoreNotFound(err)
+		}
+		u, err := internal.ToUnstructured(obj)
+		if err != nil {
+			return false, err
+		}
+		observed, found, err := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+		if err != nil {
+			return false, err
+		}
+		return found && observed >= generation, nil
+	}
+}
+
+// PhaseAtGeneration returns true if the status.phase of an object matches the passed in phase
+// and its status.observedGeneration is at least the passed in generation.
+// The state of each condition of the object is logged while waiting.
+// If an object is not found, the condition is not satisfied and no error is returned.
+func PhaseAtGeneration(obj k8s.Object, cfg *envconf.Config, phase string, generation int64) wait.ConditionWithContextFunc {
+	return func(ctx context.Context) (done bool, err error) {
+		fmtobj := fmtObj(obj)
+		klog.Infof("%s: waiting for phase %s at generation %d", fmtobj, phase, generation)
+		err = cfg.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj)
+		if err != nil {
+			return false, internal.IgnoreNotFound(err)
+		}
+		u, err := internal.ToUnstructured(obj)
+		if err != nil {
+			return false, err
+		}
+		observed, _, err := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+		if err != nil {
+			return false, err
+		}
+		current, _, err := unstructured.NestedString(u.Object, "status", "phase")
+		if err != nil {
+			return false, err
+		}
+		klog.Infof("%s: phase %q, observed generation %d", fmtobj, current, observed)
+		logConditions(fmtobj, u)
+		return current == phase && observed >= generation, nil
+	}
+}
+
+func logConditions(fmtobj string, u *unstructured.Unstructured) {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		klog.Infof("%s condition %v: %v, reason: %v, message: %v", fmtobj, c["type"], c["status"], c["reason"], c["message"])
+	}
+}
+
 func checkCondition(k8sobj k8s.Object, desiredType string, desiredStatus v1.ConditionStatus) bool {
 	fmtobj := fmtObj(k8sobj)
 	u, err := internal.ToUnstructured(k8sobj)
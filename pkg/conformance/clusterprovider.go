@@ -0,0 +1,314 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/christophrj/openmcp-testing/pkg/providers"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+// Names of the features of the cluster provider suite, they can be passed to ClusterProviderSuite.Skip.
+// FeatureInstall, FeatureProviderDeletion and FeatureLeftovers are part of the suite as well
+const (
+	FeatureClusters        = "clusters"
+	FeatureClusterRequests = "cluster-requests"
+	FeatureAccess          = "access"
+	FeatureClusterDeletion = "cluster-deletion"
+)
+
+const clusterTemplate = `
+apiVersion: clusters.openmcp.cloud/v1alpha1
+kind: Cluster
+metadata:
+  name: {{.Name}}
+spec:
+  profile: {{.Profile}}
+{{- with .Purposes}}
+  purposes:
+{{- toYaml . | nindent 4}}
+{{- end}}
+  tenancy: {{.Tenancy}}
+`
+
+// Tenancies of a cluster
+const (
+	TenancyExclusive = "Exclusive"
+	TenancyShared    = "Shared"
+)
+
+// ClusterCase describes a Cluster object that is created directly on the platform cluster
+type ClusterCase struct {
+	Name string
+	// Profile is the cluster profile that is served by the provider under test
+	Profile  string
+	Purposes []string
+	// Tenancy is either TenancyExclusive or TenancyShared
+	Tenancy string
+}
+
+// ClusterRequestCase describes a ClusterRequest for a purpose, openMCP picks or creates a Cluster to fulfil it
+type ClusterRequestCase struct {
+	Name    string
+	Purpose string
+	// Tenancy is checked against the Cluster the request has been bound to if set.
+	// The Cluster of an exclusive request is expected to be removed once the request has been deleted
+	Tenancy string
+}
+
+// ClusterProviderSuite checks that a cluster provider creates Clusters for Cluster objects and ClusterRequests
+// with different purposes and tenancies, grants access to them through AccessRequests and removes them again.
+// The features build on each other and have to be passed to a single Test call in the returned order
+type ClusterProviderSuite struct {
+	Provider providers.ClusterProviderSetup
+	// Namespace is the namespace on the platform cluster in which the objects are created,
+	// defaults to the namespace of the environment config
+	Namespace string
+	Clusters  []ClusterCase
+	Requests  []ClusterRequestCase
+	// Timeout is used to wait for each step, defaults to DefaultTimeout
+	Timeout time.Duration
+	// Skip holds the names of the features that are left out, e.g. FeatureInstall if the provider is installed by the setup
+	Skip []string
+
+	// bound holds the Clusters that have been created or bound to a request, by case name
+	bound map[string]types.NamespacedName
+}
+
+// accessRules are the permissions that are requested to check the access to a cluster
+var accessRules = []rbacv1.PolicyRule{{
+	APIGroups: []string{""},
+	Resources: []string{"namespaces"},
+	Verbs:     []string{"get", "list"},
+}}
+
+// Features returns the features of the suite that are not skipped
+func (s *ClusterProviderSuite) Features() []features.Feature {
+	all := []struct {
+		name    string
+		feature func(*features.FeatureBuilder) *features.FeatureBuilder
+	}{
+		{FeatureInstall, s.install},
+		{FeatureClusters, s.clusters},
+		{FeatureClusterRequests, s.clusterRequests},
+		{FeatureAccess, s.access},
+		{FeatureClusterDeletion, s.clusterDeletion},
+		{FeatureProviderDeletion, s.providerDeletion},
+		{FeatureLeftovers, s.leftovers},
+	}
+	var result []features.Feature
+	for _, f := range all {
+		if slices.Contains(s.Skip, f.name) {
+			klog.Infof("cluster provider %s: skipping conformance feature %s", s.Provider.Name, f.name)
+			continue
+		}
+		builder := features.New(fmt.Sprintf("cluster provider %s: %s", s.Provider.Name, f.name)).
+			WithLabel(LabelKey, f.name)
+		result = append(result, f.feature(builder).Feature())
+	}
+	return result
+}
+
+func (s *ClusterProviderSuite) waitOptions() []wait.Option {
+	return []wait.Option{wait.WithTimeout(timeout(s.Timeout))}
+}
+
+// platformConfig returns a config for the namespace of the suite on the platform cluster
+func (s *ClusterProviderSuite) platformConfig(c *envconf.Config) *envconf.Config {
+	if s.Namespace == "" {
+		return c
+	}
+	return envconf.New().WithClient(c.Client()).WithNamespace(s.Namespace)
+}
+
+func (s *ClusterProviderSuite) bind(name string, cluster types.NamespacedName) {
+	if s.bound == nil {
+		s.bound = map[string]types.NamespacedName{}
+	}
+	s.bound[name] = cluster
+}
+
+func (s *ClusterProviderSuite) install(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("install cluster provider", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if err := providers.InstallClusterProvider(untracked(ctx), c, s.Provider); err != nil {
+			t.Errorf("failed to install cluster provider %s: %v", s.Provider.Name, err)
+		}
+		return ctx
+	})
+}
+
+func (s *ClusterProviderSuite) clusters(b *features.FeatureBuilder) *features.FeatureBuilder {
+	for _, cluster := range s.Clusters {
+		b = b.Assess(fmt.Sprintf("cluster %s with tenancy %s gets ready", cluster.Name, cluster.Tenancy),
+			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+				pc := s.platformConfig(c)
+				obj, err := resources.CreateObjectFromTemplate(untracked(ctx), pc, clusterTemplate, cluster)
+				if err != nil {
+					t.Fatalf("failed to create cluster %s: %v", cluster.Name, err)
+				}
+				ref := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+				s.bind(cluster.Name, ref)
+				if err := providers.ClusterReady(ctx, pc, ref, s.waitOptions()...); err != nil {
+					t.Errorf("cluster %s failed to get ready: %v", ref, err)
+				}
+				return ctx
+			})
+	}
+	return b
+}
+
+func (s *ClusterProviderSuite) clusterRequests(b *features.FeatureBuilder) *features.FeatureBuilder {
+	for _, request := range s.Requests {
+		b = b.Assess(fmt.Sprintf("cluster request %s for purpose %s is fulfilled", request.Name, request.Purpose),
+			func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+				pc := s.platformConfig(c)
+				ref, err := providers.RequestCluster(untracked(ctx), pc, request.Name, request.Purpose, s.waitOptions()...)
+				if err != nil {
+					t.Fatalf("cluster request %s has not been fulfilled: %v", request.Name, err)
+				}
+				s.bind(request.Name, ref)
+				if err := providers.ClusterReady(ctx, pc, ref, s.waitOptions()...); err != nil {
+					t.Errorf("cluster %s of request %s failed to get ready: %v", ref, request.Name, err)
+				}
+				if request.Tenancy != "" {
+					if err := checkTenancy(ctx, pc, ref, request.Tenancy); err != nil {
+						t.Error(err)
+					}
+				}
+				return ctx
+			})
+	}
+	return b
+}
+
+func (s *ClusterProviderSuite) access(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("access is granted to each cluster", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		pc := s.platformConfig(c)
+		for name, cluster := range s.bound {
+			setup := providers.AccessRequestSetup{
+				Name:        accessRequestName(name),
+				Cluster:     &cluster,
+				Permissions: []providers.Permission{{Rules: accessRules}},
+			}
+			accessCfg, err := providers.RequestAccess(untracked(ctx), pc, setup, s.waitOptions()...)
+			if err != nil {
+				t.Errorf("failed to get access to cluster %s: %v", cluster, err)
+				continue
+			}
+			namespaces := &corev1.NamespaceList{}
+			if err := accessCfg.Client().Resources().List(ctx, namespaces); err != nil {
+				t.Errorf("failed to list namespaces of cluster %s with the granted access: %v", cluster, err)
+			}
+			ref := types.NamespacedName{Namespace: pc.Namespace(), Name: setup.Name}
+			if err := providers.DeleteAccessRequest(ctx, pc, ref, s.waitOptions()...); err != nil {
+				t.Errorf("failed to delete access request for cluster %s: %v", cluster, err)
+			}
+		}
+		return ctx
+	})
+}
+
+func (s *ClusterProviderSuite) clusterDeletion(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("delete cluster requests", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		pc := s.platformConfig(c)
+		for _, request := range s.Requests {
+			ref := types.NamespacedName{Namespace: pc.Namespace(), Name: request.Name}
+			if err := providers.DeleteClusterRequest(ctx, pc, ref, s.waitOptions()...); err != nil {
+				t.Errorf("failed to delete cluster request %s: %v", request.Name, err)
+			}
+		}
+		return ctx
+	}).
+		Assess("clusters of exclusive cluster requests are removed", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			list := s.exclusiveRequestClusters()
+			if len(list.Items) == 0 {
+				t.Skip("no cluster has been bound to an exclusive cluster request")
+			}
+			pc := s.platformConfig(c)
+			if err := wait.For(conditions.New(pc.Client().Resources()).ResourcesDeleted(list), s.waitOptions()...); err != nil {
+				t.Errorf("clusters of exclusive cluster requests have not been removed: %v", err)
+			}
+			return ctx
+		}).
+		Assess("delete clusters", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			pc := s.platformConfig(c)
+			for _, cluster := range s.Clusters {
+				ref := types.NamespacedName{Namespace: pc.Namespace(), Name: cluster.Name}
+				if err := providers.DeleteCluster(ctx, pc, ref, s.waitOptions()...); err != nil {
+					t.Errorf("failed to delete cluster %s: %v", ref, err)
+				}
+			}
+			return ctx
+		})
+}
+
+func (s *ClusterProviderSuite) providerDeletion(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("delete cluster provider", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if err := providers.DeleteClusterProvider(ctx, c, s.Provider.Name, s.waitOptions()...); err != nil {
+			t.Errorf("failed to delete cluster provider %s: %v", s.Provider.Name, err)
+		}
+		return ctx
+	})
+}
+
+func (s *ClusterProviderSuite) leftovers(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("no requests and clusters are left", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		pc := s.platformConfig(c)
+		list := &unstructured.UnstructuredList{}
+		for _, request := range s.Requests {
+			list.Items = append(list.Items, *providers.ClusterRequestRef(types.NamespacedName{Namespace: pc.Namespace(), Name: request.Name}))
+		}
+		for name := range s.bound {
+			list.Items = append(list.Items, *providers.AccessRequestRef(types.NamespacedName{Namespace: pc.Namespace(), Name: accessRequestName(name)}))
+		}
+		for _, cluster := range s.Clusters {
+			list.Items = append(list.Items, *providers.ClusterRef(types.NamespacedName{Namespace: pc.Namespace(), Name: cluster.Name}))
+		}
+		list.Items = append(list.Items, s.exclusiveRequestClusters().Items...)
+		if err := assertDeleted(pc, list); err != nil {
+			t.Errorf("objects are left: %v", err)
+		}
+		return ctx
+	})
+}
+
+// exclusiveRequestClusters returns the Clusters that have been bound to exclusive cluster requests,
+// they are expected to be removed by the provider together with their request
+func (s *ClusterProviderSuite) exclusiveRequestClusters() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	for _, request := range s.Requests {
+		cluster, ok := s.bound[request.Name]
+		if ok && request.Tenancy == TenancyExclusive {
+			list.Items = append(list.Items, *providers.ClusterRef(cluster))
+		}
+	}
+	return list
+}
+
+func checkTenancy(ctx context.Context, c *envconf.Config, ref types.NamespacedName, tenancy string) error {
+	cluster := providers.ClusterRef(ref)
+	if err := c.Client().Resources().Get(ctx, ref.Name, ref.Namespace, cluster); err != nil {
+		return err
+	}
+	actual, _, _ := unstructured.NestedString(cluster.Object, "spec", "tenancy")
+	if actual != tenancy {
+		return fmt.Errorf("cluster %s has tenancy %q, expected %q", ref, actual, tenancy)
+	}
+	return nil
+}
+
+func accessRequestName(name string) string {
+	return "conformance-" + name
+}
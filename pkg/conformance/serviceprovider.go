@@ -0,0 +1,233 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
+	"github.com/christophrj/openmcp-testing/pkg/providers"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+// Names of the features of the service provider suite, they can be passed to ServiceProviderSuite.Skip
+const (
+	FeatureInstall          = "install"
+	FeatureOnboarding       = "onboarding"
+	FeatureUpdate           = "update"
+	FeatureMCPDeletion      = "mcp-deletion"
+	FeatureProviderDeletion = "provider-deletion"
+	FeatureLeftovers        = "leftovers"
+)
+
+// LabelKey is the feature label that holds the name of a conformance feature, e.g. to select features with --labels
+const LabelKey = "conformance"
+
+// DefaultTimeout is used to wait for each step of a suite if no timeout is configured
+const DefaultTimeout = 5 * time.Minute
+
+// ServiceProviderSuite runs the scenario every service provider has to support: the provider is installed,
+// an MCP is created, the onboarding API is applied and the domain API shows up on the MCP.
+// Afterwards everything is deleted again and checked for leftovers.
+// The features build on each other and have to be passed to a single Test call in the returned order
+type ServiceProviderSuite struct {
+	Provider providers.ServiceProviderSetup
+	// OnboardingDir contains the objects of the service provider API that are applied to the onboarding cluster
+	OnboardingDir string
+	// DomainDir contains the objects that are expected on the MCP cluster once the onboarding objects have been reconciled
+	DomainDir string
+	// UpdateDir contains changed onboarding objects that are applied by the update feature.
+	// The update feature is skipped if it is not set
+	UpdateDir string
+	// UpdatedDomainDir contains the objects that are expected on the MCP cluster after the update
+	UpdatedDomainDir string
+	// MCPName is the name of the MCP that is created for the suite, defaults to conformance-<provider name>
+	MCPName    string
+	MCPOptions []providers.MCPOption
+	// Timeout is used to wait for each step, defaults to DefaultTimeout
+	Timeout time.Duration
+	// Skip holds the names of the features that are left out, e.g. FeatureInstall if the provider is installed by the setup
+	Skip []string
+
+	onboarded *unstructured.UnstructuredList
+}
+
+// Features returns the features of the suite that are not skipped
+func (s *ServiceProviderSuite) Features() []features.Feature {
+	all := []struct {
+		name    string
+		feature func(*features.FeatureBuilder) *features.FeatureBuilder
+	}{
+		{FeatureInstall, s.install},
+		{FeatureOnboarding, s.onboarding},
+		{FeatureUpdate, s.update},
+		{FeatureMCPDeletion, s.mcpDeletion},
+		{FeatureProviderDeletion, s.providerDeletion},
+		{FeatureLeftovers, s.leftovers},
+	}
+	var result []features.Feature
+	for _, f := range all {
+		if slices.Contains(s.Skip, f.name) || (f.name == FeatureUpdate && s.UpdateDir == "") {
+			klog.Infof("service provider %s: skipping conformance feature %s", s.Provider.Name, f.name)
+			continue
+		}
+		builder := features.New(fmt.Sprintf("service provider %s: %s", s.Provider.Name, f.name)).
+			WithLabel(LabelKey, f.name)
+		result = append(result, f.feature(builder).Feature())
+	}
+	return result
+}
+
+func (s *ServiceProviderSuite) mcpName() string {
+	if s.MCPName != "" {
+		return s.MCPName
+	}
+	return "conformance-" + s.Provider.Name
+}
+
+func (s *ServiceProviderSuite) mcpNamespace() string {
+	return providers.NewMCPSpec(s.mcpName(), s.MCPOptions...).Namespace
+}
+
+func (s *ServiceProviderSuite) waitOptions() []wait.Option {
+	return []wait.Option{wait.WithTimeout(timeout(s.Timeout))}
+}
+
+func (s *ServiceProviderSuite) install(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("install service provider", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if err := providers.InstallServiceProvider(untracked(ctx), c, s.Provider); err != nil {
+			t.Errorf("failed to install service provider %s: %v", s.Provider.Name, err)
+		}
+		return ctx
+	})
+}
+
+func (s *ServiceProviderSuite) onboarding(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Setup(func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		ret := providers.CreateMCP(s.mcpName(), timeout(s.Timeout), s.MCPOptions...)(untracked(ctx), t, c)
+		// the MCP outlives the feature, but objects created later in the feature are still cleaned up by its tracker
+		return resources.WithTracker(ret, resources.TrackerFromContext(ctx))
+	}).
+		Setup(func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			objList, err := clusterutils.ImportToOnboardingCluster(untracked(ctx), s.OnboardingDir, s.waitOptions()...)
+			// only objects created by the suite are deleted and checked for leftovers
+			s.onboarded = resources.CreatedByRun(objList)
+			if err != nil {
+				t.Error(err)
+			}
+			return ctx
+		}).
+		Assess("onboarding API objects exist", providers.AssertServiceProviderAPIs(s.OnboardingDir, s.waitOptions()...)).
+		Assess("domain API objects exist on the MCP", providers.AssertDomainAPIsOnMCP(s.mcpName(), s.DomainDir, s.waitOptions()...))
+}
+
+func (s *ServiceProviderSuite) update(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Setup(func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		objList, err := clusterutils.ImportToOnboardingCluster(untracked(ctx), s.UpdateDir, s.waitOptions()...)
+		if objList != nil {
+			// objects that are only part of the update are deleted together with the onboarded objects
+			if s.onboarded == nil {
+				s.onboarded = &unstructured.UnstructuredList{}
+			}
+			s.onboarded.Items = append(s.onboarded.Items, resources.CreatedByRun(objList).Items...)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}).
+		Assess("updated onboarding API objects exist", providers.AssertServiceProviderAPIs(s.UpdateDir, s.waitOptions()...)).
+		Assess("updated domain API objects exist on the MCP", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			if s.UpdatedDomainDir == "" {
+				t.Skip("no updated domain objects configured")
+			}
+			return providers.AssertDomainAPIsOnMCP(s.mcpName(), s.UpdatedDomainDir, s.waitOptions()...)(ctx, t, c)
+		})
+}
+
+func (s *ServiceProviderSuite) mcpDeletion(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("delete onboarding API objects", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if s.onboarded == nil {
+			t.Skip("no onboarding API objects have been created")
+		}
+		onboardingCfg, err := clusterutils.OnboardingConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := resources.DeleteObjects(ctx, onboardingCfg, s.onboarded, s.waitOptions()...); err != nil {
+			t.Errorf("failed to delete onboarding API objects: %v", err)
+		}
+		return ctx
+	}).
+		Assess("delete MCP", providers.DeleteMCPInNamespace(s.mcpName(), s.mcpNamespace(), s.waitOptions()...))
+}
+
+func (s *ServiceProviderSuite) providerDeletion(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("delete service provider", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if err := providers.UninstallServiceProvider(ctx, c, s.Provider, s.waitOptions()...); err != nil {
+			t.Errorf("failed to delete service provider %s: %v", s.Provider.Name, err)
+		}
+		return ctx
+	})
+}
+
+func (s *ServiceProviderSuite) leftovers(b *features.FeatureBuilder) *features.FeatureBuilder {
+	return b.Assess("no onboarding API objects are left", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		if s.onboarded == nil {
+			t.Skip("no onboarding API objects have been created")
+		}
+		onboardingCfg, err := clusterutils.OnboardingConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := assertDeleted(onboardingCfg, s.onboarded); err != nil {
+			t.Errorf("onboarding API objects are left: %v", err)
+		}
+		return ctx
+	}).
+		Assess("no MCP is left", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			onboardingCfg, err := clusterutils.OnboardingConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			mcp := providers.MCPRef(types.NamespacedName{Namespace: s.mcpNamespace(), Name: s.mcpName()})
+			if err := assertDeleted(onboardingCfg, &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*mcp}}); err != nil {
+				t.Errorf("MCP is left: %v", err)
+			}
+			return ctx
+		}).
+		Assess("no service provider is left", func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+			sp := providers.ServiceProviderRef(s.Provider.Name)
+			if err := assertDeleted(c, &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*sp}}); err != nil {
+				t.Errorf("service provider is left: %v", err)
+			}
+			return ctx
+		})
+}
+
+// assertDeleted returns an error if one of the objects still exists
+func assertDeleted(c *envconf.Config, list *unstructured.UnstructuredList) error {
+	return wait.For(conditions.New(c.Client().Resources()).ResourcesDeleted(list), wait.WithImmediate(), wait.WithTimeout(time.Second))
+}
+
+// untracked returns a context whose created objects are not recorded by the tracker of the feature,
+// because the objects of a suite have to outlive the feature that created them
+func untracked(ctx context.Context) context.Context {
+	return resources.WithTracker(ctx, nil)
+}
+
+func timeout(t time.Duration) time.Duration {
+	if t > 0 {
+		return t
+	}
+	return DefaultTimeout
+}
@@ -2,11 +2,8 @@ package providers
 
 import (
 	"context"
-	"testing"
-	"time"
 
 	"github.com/christophrj/openmcp-testing/internal"
-	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
 	"github.com/christophrj/openmcp-testing/pkg/conditions"
 	"github.com/christophrj/openmcp-testing/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
@@ -16,7 +13,6 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
-	"sigs.k8s.io/e2e-framework/pkg/features"
 )
 
 const clusterProviderTemplate = `
@@ -25,46 +21,61 @@ kind: ClusterProvider
 metadata:
   name: {{.Name}}
 spec:
-  image: {{.Image}}
-  extraVolumeMounts:
-    - mountPath: /var/run/docker.sock
-      name: docker
-  extraVolumes:
-    - name: docker
-      hostPath:
-        path: /var/run/host-docker.sock
-        type: Socket
-`
-
-const mcpTemplate = `
-apiVersion: core.openmcp.cloud/v2alpha1
-kind: ManagedControlPlaneV2
-metadata:
-  name: {{.Name}}
-spec:
-  iam: {}
-`
+  image: {{.Image}}` + deploymentSpecTemplate
 
 // ClusterProviderSetup represents the configuration parameters to set up a cluster provider
 type ClusterProviderSetup struct {
 	Name  string
 	Image string
-	Opts  []wait.Option
+	// ImagePullSecrets are the names of the secrets in the openMCP namespace that are used to pull the image
+	ImagePullSecrets []string
+	// InitCommand and RunCommand are passed as arguments to the init and run commands of the provider
+	InitCommand []string
+	RunCommand  []string
+	// Env are the environment variables of the provider deployment
+	Env []EnvVar
+	// Verbosity is the log level of the provider, e.g. DEBUG
+	Verbosity string
+	// MountDockerSocket mounts the docker socket of the host into the provider, e.g. for the kind cluster provider
+	// that creates clusters as docker containers
+	MountDockerSocket bool
+	ExtraVolumes      []corev1.Volume
+	ExtraVolumeMounts []corev1.VolumeMount
+	// Overlay is deep merged into the rendered ClusterProvider object,
+	// e.g. {"spec": {"runReplicas": 2}} for fields that have no dedicated setting
+	Overlay map[string]interface{}
+	Opts    []wait.Option
 }
 
-func mcpRef(ref types.NamespacedName) *unstructured.Unstructured {
-	return internal.UnstructuredRef(ref.Name, ref.Namespace, schema.GroupVersionKind{
-		Group:   "core.openmcp.cloud",
-		Version: "v2alpha1",
-		Kind:    "managedcontrolplanev2",
+// templateData returns the setup that is used to render the ClusterProvider template,
+// with the docker socket of the host added to the volumes if requested
+func (s ClusterProviderSetup) templateData() ClusterProviderSetup {
+	if !s.MountDockerSocket {
+		return s
+	}
+	socket := corev1.HostPathSocket
+	s.ExtraVolumes = append(append([]corev1.Volume{}, s.ExtraVolumes...), corev1.Volume{
+		Name: "docker",
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: "/var/run/host-docker.sock",
+				Type: &socket,
+			},
+		},
+	})
+	s.ExtraVolumeMounts = append(append([]corev1.VolumeMount{}, s.ExtraVolumeMounts...), corev1.VolumeMount{
+		Name:      "docker",
+		MountPath: "/var/run/docker.sock",
 	})
+	return s
 }
 
-func clusterRef(ref types.NamespacedName) *unstructured.Unstructured {
+// ClusterRef returns a reference to the referenced Cluster object on the platform cluster
+func ClusterRef(ref types.NamespacedName) *unstructured.Unstructured {
 	return internal.UnstructuredRef(ref.Name, ref.Namespace, schema.GroupVersionKind{
 		Group:   "clusters.openmcp.cloud",
 		Version: "v1alpha1",
-		Kind:    "cluster",
+		Kind:    "Cluster",
 	})
 }
 
@@ -79,7 +90,8 @@ func clusterProviderRef(name string) *unstructured.Unstructured {
 // InstallClusterProvider creates a cluster provider object on the platform cluster and waits until it is ready
 func InstallClusterProvider(ctx context.Context, c *envconf.Config, clusterProvider ClusterProviderSetup) error {
 	klog.Infof("create cluster provider %s", clusterProvider.Name)
-	obj, err := resources.CreateObjectFromTemplate(ctx, c, clusterProviderTemplate, clusterProvider)
+	obj, err := resources.CreateObjectFromTemplate(ctx, c, clusterProviderTemplate, clusterProvider.templateData(),
+		resources.Overlay(clusterProvider.Overlay))
 	if err != nil {
 		return err
 	}
@@ -92,55 +104,9 @@ func DeleteClusterProvider(ctx context.Context, c *envconf.Config, name string,
 	return resources.DeleteObject(ctx, c, clusterProviderRef(name), opts...)
 }
 
-// CreateMCP creates an MCP object on the onboarding cluster and waits until it is ready
-func CreateMCP(name string, timeout time.Duration) features.Func {
-	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
-		klog.Infof("create MCP: %s", name)
-		onboardingCfg, err := clusterutils.OnboardingConfig()
-		if err != nil {
-			t.Error(err)
-			return ctx
-		}
-		obj, err := resources.CreateObjectFromTemplate(ctx, onboardingCfg, mcpTemplate, struct{ Name string }{Name: name})
-		if err != nil {
-			t.Errorf("failed to create MCP: %v", err)
-			return ctx
-		}
-		if err := wait.For(
-			conditions.Status(obj, onboardingCfg, "phase", "Ready"),
-			wait.WithTimeout(timeout),
-		); err != nil {
-			t.Errorf("MCP failed to get ready: %v", err)
-		}
-		return ctx
-	}
-}
-
-// DeleteMCP deletes the MCP object on the onboarding cluster and waits until the object has been deleted
-func DeleteMCP(name string, opts ...wait.Option) features.Func {
-	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
-		klog.Infof("delete MCP: %s", name)
-		onboardingCfg, err := clusterutils.OnboardingConfig()
-		if err != nil {
-			t.Error(err)
-			return ctx
-		}
-		mcp := mcpRef(types.NamespacedName{
-			Namespace: corev1.NamespaceDefault,
-			Name:      name,
-		})
-		err = resources.DeleteObject(ctx, onboardingCfg, mcp, opts...)
-		if err != nil {
-			t.Errorf("failed to delete MCP %s: %v", name, err)
-			return ctx
-		}
-		return ctx
-	}
-}
-
 // ClusterReady returns true if the referenced cluster object is ready
 func ClusterReady(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) error {
-	if err := wait.For(conditions.Match(clusterRef(ref), c, "Ready", corev1.ConditionTrue), options...); err != nil {
+	if err := wait.For(conditions.Match(ClusterRef(ref), c, "Ready", corev1.ConditionTrue), options...); err != nil {
 		return err
 	}
 	klog.Infof("cluster ready: %s", ref)
@@ -150,5 +116,5 @@ func ClusterReady(ctx context.Context, c *envconf.Config, ref types.NamespacedNa
 // DeleteCluster deletes the referenced cluster object
 func DeleteCluster(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) error {
 	klog.Infof("delete cluster: %s", ref)
-	return resources.DeleteObject(ctx, c, clusterRef(ref), options...)
+	return resources.DeleteObject(ctx, c, ClusterRef(ref), options...)
 }
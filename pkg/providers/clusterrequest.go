@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"github.com/christophrj/openmcp-testing/pkg/conditions"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+const clusterRequestTemplate = `
+apiVersion: clusters.openmcp.cloud/v1alpha1
+kind: ClusterRequest
+metadata:
+  name: {{.Name}}
+spec:
+  purpose: {{.Purpose}}
+`
+
+const accessRequestTemplate = `
+apiVersion: clusters.openmcp.cloud/v1alpha1
+kind: AccessRequest
+metadata:
+  name: {{.Name}}
+spec:
+{{- with .Cluster}}
+  clusterRef:
+    name: {{.Name}}
+    namespace: {{.Namespace}}
+{{- end}}
+{{- with .Request}}
+  requestRef:
+    name: {{.Name}}
+    namespace: {{.Namespace}}
+{{- end}}
+{{- with .Permissions}}
+  permissions:
+{{- toYaml . | nindent 4}}
+{{- end}}
+`
+
+// phaseGranted is the phase of a ClusterRequest or AccessRequest that has been fulfilled
+const phaseGranted = "Granted"
+
+// AccessRequestSetup represents the configuration parameters to request access to a cluster.
+// Either Cluster or Request has to be set
+type AccessRequestSetup struct {
+	Name string
+	// Cluster references the Cluster to which access is requested
+	Cluster *types.NamespacedName
+	// Request references a ClusterRequest, access is requested to the Cluster it has been bound to
+	Request     *types.NamespacedName
+	Permissions []Permission
+}
+
+// Permission represents the rules that are granted by an AccessRequest,
+// in the passed in namespace of the cluster or cluster wide if the namespace is empty
+type Permission struct {
+	Namespace string              `json:"namespace,omitempty"`
+	Rules     []rbacv1.PolicyRule `json:"rules"`
+}
+
+// ClusterRequestRef returns a reference to the referenced ClusterRequest object on the platform cluster
+func ClusterRequestRef(ref types.NamespacedName) *unstructured.Unstructured {
+	return internal.UnstructuredRef(ref.Name, ref.Namespace, schema.GroupVersionKind{
+		Group:   "clusters.openmcp.cloud",
+		Version: "v1alpha1",
+		Kind:    "ClusterRequest",
+	})
+}
+
+// AccessRequestRef returns a reference to the referenced AccessRequest object on the platform cluster
+func AccessRequestRef(ref types.NamespacedName) *unstructured.Unstructured {
+	return internal.UnstructuredRef(ref.Name, ref.Namespace, schema.GroupVersionKind{
+		Group:   "clusters.openmcp.cloud",
+		Version: "v1alpha1",
+		Kind:    "AccessRequest",
+	})
+}
+
+// CreateClusterRequest creates a ClusterRequest for the passed in purpose in the namespace of the config
+func CreateClusterRequest(ctx context.Context, c *envconf.Config, name string, purpose string) error {
+	klog.Infof("create cluster request %s for purpose %s", name, purpose)
+	data := struct {
+		Name    string
+		Purpose string
+	}{Name: name, Purpose: purpose}
+	_, err := resources.CreateObjectFromTemplate(ctx, c, clusterRequestTemplate, data)
+	return err
+}
+
+// WaitForClusterRequest waits until the ClusterRequest has been granted and returns the Cluster it has been bound to
+func WaitForClusterRequest(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) (types.NamespacedName, error) {
+	request := ClusterRequestRef(ref)
+	if err := wait.For(conditions.Status(request, c, "phase", phaseGranted), options...); err != nil {
+		return types.NamespacedName{}, err
+	}
+	return boundCluster(request)
+}
+
+// RequestCluster creates a ClusterRequest for the passed in purpose in the namespace of the config,
+// waits until it has been granted and returns the Cluster it has been bound to
+func RequestCluster(ctx context.Context, c *envconf.Config, name string, purpose string, options ...wait.Option) (types.NamespacedName, error) {
+	if err := CreateClusterRequest(ctx, c, name, purpose); err != nil {
+		return types.NamespacedName{}, err
+	}
+	return WaitForClusterRequest(ctx, c, types.NamespacedName{Namespace: c.Namespace(), Name: name}, options...)
+}
+
+// BoundCluster returns the Cluster the ClusterRequest has been bound to
+func BoundCluster(ctx context.Context, c *envconf.Config, ref types.NamespacedName) (types.NamespacedName, error) {
+	request := ClusterRequestRef(ref)
+	if err := c.Client().Resources().Get(ctx, ref.Name, ref.Namespace, request); err != nil {
+		return types.NamespacedName{}, err
+	}
+	return boundCluster(request)
+}
+
+func boundCluster(request *unstructured.Unstructured) (types.NamespacedName, error) {
+	name, _, err := unstructured.NestedString(request.Object, "status", "cluster", "name")
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	if name == "" {
+		return types.NamespacedName{}, fmt.Errorf("cluster request %s/%s has not been bound to a cluster", request.GetNamespace(), request.GetName())
+	}
+	namespace, _, err := unstructured.NestedString(request.Object, "status", "cluster", "namespace")
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	if namespace == "" {
+		namespace = request.GetNamespace()
+	}
+	klog.Infof("cluster request %s/%s bound to cluster %s/%s", request.GetNamespace(), request.GetName(), namespace, name)
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// DeleteClusterRequest deletes the referenced ClusterRequest
+func DeleteClusterRequest(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) error {
+	klog.Infof("delete cluster request: %s", ref)
+	return resources.DeleteObject(ctx, c, ClusterRequestRef(ref), options...)
+}
+
+// CreateAccessRequest creates an AccessRequest in the namespace of the config
+func CreateAccessRequest(ctx context.Context, c *envconf.Config, setup AccessRequestSetup) error {
+	if (setup.Cluster == nil) == (setup.Request == nil) {
+		return fmt.Errorf("access request %s has to reference either a cluster or a cluster request", setup.Name)
+	}
+	klog.Infof("create access request %s", setup.Name)
+	_, err := resources.CreateObjectFromTemplate(ctx, c, accessRequestTemplate, setup)
+	return err
+}
+
+// WaitForAccess waits until the AccessRequest has been granted and returns a config
+// that acts on the cluster with the granted kubeconfig
+func WaitForAccess(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) (*envconf.Config, error) {
+	request := AccessRequestRef(ref)
+	if err := wait.For(conditions.Status(request, c, "phase", phaseGranted), options...); err != nil {
+		return nil, err
+	}
+	secretName, _, err := unstructured.NestedString(request.Object, "status", "secretRef", "name")
+	if err != nil {
+		return nil, err
+	}
+	secretNamespace, _, err := unstructured.NestedString(request.Object, "status", "secretRef", "namespace")
+	if err != nil {
+		return nil, err
+	}
+	if secretNamespace == "" {
+		secretNamespace = ref.Namespace
+	}
+	secret := &corev1.Secret{}
+	if err := c.Client().Resources().Get(ctx, secretName, secretNamespace, secret); err != nil {
+		return nil, fmt.Errorf("failed to retrieve secret of access request %s: %w", ref, err)
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(secret.Data["kubeconfig"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig of access request %s: %w", ref, err)
+	}
+	client, err := klient.New(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("access request granted: %s", ref)
+	return envconf.New().WithClient(client).WithNamespace(corev1.NamespaceDefault), nil
+}
+
+// RequestAccess creates an AccessRequest in the namespace of the config, waits until it has been granted
+// and returns a config that acts on the cluster with the granted kubeconfig
+func RequestAccess(ctx context.Context, c *envconf.Config, setup AccessRequestSetup, options ...wait.Option) (*envconf.Config, error) {
+	if err := CreateAccessRequest(ctx, c, setup); err != nil {
+		return nil, err
+	}
+	return WaitForAccess(ctx, c, types.NamespacedName{Namespace: c.Namespace(), Name: setup.Name}, options...)
+}
+
+// DeleteAccessRequest deletes the referenced AccessRequest
+func DeleteAccessRequest(ctx context.Context, c *envconf.Config, ref types.NamespacedName, options ...wait.Option) error {
+	klog.Infof("delete access request: %s", ref)
+	return resources.DeleteObject(ctx, c, AccessRequestRef(ref), options...)
+}
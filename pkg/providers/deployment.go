@@ -0,0 +1,41 @@
+package providers
+
+// EnvVar represents an environment variable that is passed to the deployment of a provider
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// deploymentSpecTemplate renders the deployment fields that cluster and service providers have in common.
+// It is appended to the spec of a provider template
+const deploymentSpecTemplate = `
+{{- with .ImagePullSecrets}}
+  imagePullSecrets:
+{{- range .}}
+    - name: {{.}}
+{{- end}}
+{{- end}}
+{{- with .InitCommand}}
+  initCommand:
+{{- toYaml . | nindent 4}}
+{{- end}}
+{{- with .RunCommand}}
+  runCommand:
+{{- toYaml . | nindent 4}}
+{{- end}}
+{{- with .Env}}
+  env:
+{{- toYaml . | nindent 4}}
+{{- end}}
+{{- with .Verbosity}}
+  verbosity: {{.}}
+{{- end}}
+{{- with .ExtraVolumes}}
+  extraVolumes:
+{{- toYaml . | nindent 4}}
+{{- end}}
+{{- with .ExtraVolumeMounts}}
+  extraVolumeMounts:
+{{- toYaml . | nindent 4}}
+{{- end}}
+`
@@ -0,0 +1,551 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
+	"github.com/christophrj/openmcp-testing/pkg/conditions"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+const mcpTemplate = `
+apiVersion: core.openmcp.cloud/v2alpha1
+kind: ManagedControlPlaneV2
+metadata:
+  name: {{.Name}}
+{{- with .Labels}}
+  labels:
+{{- toYaml . | nindent 4}}
+{{- end}}
+{{- with .Annotations}}
+  annotations:
+{{- toYaml . | nindent 4}}
+{{- end}}
+spec:
+  iam:
+{{- toYaml .IAM | nindent 4}}
+`
+
+type mcpNameContextKey struct{}
+
+type mcpNamespaceContextKey struct {
+	name string
+}
+
+type mcpConfigContextKey struct {
+	name string
+}
+
+// McpNameFromContext returns the name of the MCP that has been created last by CreateMCP within a feature
+func McpNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(mcpNameContextKey{}).(string)
+	return name
+}
+
+// McpNamespaceFromContext returns the namespace on the onboarding cluster in which CreateMCP has created the MCP
+// with the passed in name within a feature. The default namespace is returned for unknown MCPs
+func McpNamespaceFromContext(ctx context.Context, name string) string {
+	if namespace, ok := ctx.Value(mcpNamespaceContextKey{name: name}).(string); ok {
+		return namespace
+	}
+	return corev1.NamespaceDefault
+}
+
+// MCPSubject references a user, group or service account that is granted access to an MCP
+type MCPSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// MCPRoleRef references a (Cluster)Role on the MCP cluster
+type MCPRoleRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// MCPRoleBinding binds subjects to roles on the MCP cluster
+type MCPRoleBinding struct {
+	Subjects []MCPSubject `json:"subjects"`
+	RoleRefs []MCPRoleRef `json:"roleRefs"`
+}
+
+// MCPOIDCProvider configures an additional OIDC provider that is trusted by the MCP cluster
+type MCPOIDCProvider struct {
+	Name           string           `json:"name"`
+	Issuer         string           `json:"issuer"`
+	ClientID       string           `json:"clientID"`
+	UsernameClaim  string           `json:"usernameClaim,omitempty"`
+	GroupsClaim    string           `json:"groupsClaim,omitempty"`
+	UsernamePrefix string           `json:"usernamePrefix,omitempty"`
+	GroupsPrefix   string           `json:"groupsPrefix,omitempty"`
+	ExtraScopes    []string         `json:"extraScopes,omitempty"`
+	RoleBindings   []MCPRoleBinding `json:"roleBindings,omitempty"`
+}
+
+// MCPIAM represents the IAM configuration of an MCP
+type MCPIAM struct {
+	OIDC *MCPOIDC `json:"oidc,omitempty"`
+}
+
+// MCPOIDC represents the OIDC configuration of an MCP
+type MCPOIDC struct {
+	DefaultProvider *MCPDefaultProvider `json:"defaultProvider,omitempty"`
+	ExtraProviders  []MCPOIDCProvider   `json:"extraProviders,omitempty"`
+}
+
+// MCPDefaultProvider represents the configuration of the default OIDC provider of the platform
+type MCPDefaultProvider struct {
+	RoleBindings []MCPRoleBinding `json:"roleBindings,omitempty"`
+}
+
+// MCPSpec describes a ManagedControlPlaneV2 object, it is built by passing MCPOptions to CreateMCP
+type MCPSpec struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	IAM         MCPIAM
+	// Overrides are deep merged into the spec of the MCP
+	Overrides map[string]interface{}
+
+	// placed is set if the namespace has been chosen by an option
+	placed bool
+	// project and workspace are set by MCPInProject and MCPInWorkspace, their namespace is resolved when the MCP is created
+	project   string
+	workspace string
+}
+
+// MCPOption configures the MCP that is created by CreateMCP
+type MCPOption func(*MCPSpec)
+
+// NewMCPSpec returns the description of an MCP with the passed in options applied
+func NewMCPSpec(name string, opts ...MCPOption) *MCPSpec {
+	spec := &MCPSpec{Name: name, Namespace: corev1.NamespaceDefault}
+	for _, opt := range opts {
+		opt(spec)
+	}
+	return spec
+}
+
+// MCPNamespace creates the MCP in the passed in namespace of the onboarding cluster instead of the default namespace.
+// The namespace is created if it does not exist yet
+func MCPNamespace(namespace string) MCPOption {
+	return func(s *MCPSpec) {
+		s.Namespace = namespace
+		s.placed = true
+		s.project, s.workspace = "", ""
+	}
+}
+
+// MCPInProject creates the MCP in the namespace of the passed in project. The namespace reported to CreateProject
+// within the same feature is used, see ProjectNamespaceFromContext. The project has to exist
+func MCPInProject(project string) MCPOption {
+	return func(s *MCPSpec) {
+		s.Namespace = ProjectNamespace(project)
+		s.placed = true
+		s.project, s.workspace = project, ""
+	}
+}
+
+// MCPInWorkspace creates the MCP in the namespace of the passed in workspace of a project. The namespace reported to
+// CreateWorkspace within the same feature is used, see WorkspaceNamespaceFromContext. The workspace has to exist
+func MCPInWorkspace(project string, workspace string) MCPOption {
+	return func(s *MCPSpec) {
+		s.Namespace = WorkspaceNamespace(project, workspace)
+		s.placed = true
+		s.project, s.workspace = project, workspace
+	}
+}
+
+// resolveNamespace sets the namespace of an MCP in a project or workspace to the one reported within the feature
+func (s *MCPSpec) resolveNamespace(ctx context.Context) {
+	switch {
+	case s.workspace != "":
+		s.Namespace = WorkspaceNamespaceFromContext(ctx, s.project, s.workspace)
+	case s.project != "":
+		s.Namespace = ProjectNamespaceFromContext(ctx, s.project)
+	}
+}
+
+// MCPLabels adds labels to the MCP object
+func MCPLabels(labels map[string]string) MCPOption {
+	return func(s *MCPSpec) {
+		s.Labels = mergeStrings(s.Labels, labels)
+	}
+}
+
+// MCPAnnotations adds annotations to the MCP object
+func MCPAnnotations(annotations map[string]string) MCPOption {
+	return func(s *MCPSpec) {
+		s.Annotations = mergeStrings(s.Annotations, annotations)
+	}
+}
+
+// MCPRoleBindings grants subjects of the default OIDC provider access to the MCP cluster
+func MCPRoleBindings(bindings ...MCPRoleBinding) MCPOption {
+	return func(s *MCPSpec) {
+		if s.IAM.OIDC == nil {
+			s.IAM.OIDC = &MCPOIDC{}
+		}
+		if s.IAM.OIDC.DefaultProvider == nil {
+			s.IAM.OIDC.DefaultProvider = &MCPDefaultProvider{}
+		}
+		s.IAM.OIDC.DefaultProvider.RoleBindings = append(s.IAM.OIDC.DefaultProvider.RoleBindings, bindings...)
+	}
+}
+
+// MCPUser binds a user of the default OIDC provider to the passed in cluster roles
+func MCPUser(name string, clusterRoles ...string) MCPOption {
+	return MCPRoleBindings(MCPRoleBinding{
+		Subjects: []MCPSubject{{Kind: "User", Name: name}},
+		RoleRefs: clusterRoleRefs(clusterRoles),
+	})
+}
+
+// MCPGroup binds a group of the default OIDC provider to the passed in cluster roles
+func MCPGroup(name string, clusterRoles ...string) MCPOption {
+	return MCPRoleBindings(MCPRoleBinding{
+		Subjects: []MCPSubject{{Kind: "Group", Name: name}},
+		RoleRefs: clusterRoleRefs(clusterRoles),
+	})
+}
+
+// MCPOIDCProviders adds OIDC providers that are trusted by the MCP cluster in addition to the default provider
+func MCPOIDCProviders(providers ...MCPOIDCProvider) MCPOption {
+	return func(s *MCPSpec) {
+		if s.IAM.OIDC == nil {
+			s.IAM.OIDC = &MCPOIDC{}
+		}
+		s.IAM.OIDC.ExtraProviders = append(s.IAM.OIDC.ExtraProviders, providers...)
+	}
+}
+
+// MCPSpecOverrides deep merges arbitrary fields into the spec of the MCP, e.g. to configure components
+func MCPSpecOverrides(overrides map[string]interface{}) MCPOption {
+	return func(s *MCPSpec) {
+		if s.Overrides == nil {
+			s.Overrides = map[string]interface{}{}
+		}
+		for k, v := range overrides {
+			s.Overrides[k] = v
+		}
+	}
+}
+
+func clusterRoleRefs(names []string) []MCPRoleRef {
+	refs := make([]MCPRoleRef, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, MCPRoleRef{Kind: "ClusterRole", Name: name})
+	}
+	return refs
+}
+
+func mergeStrings(dst map[string]string, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// MCPRef returns a reference to the referenced ManagedControlPlaneV2 object on the onboarding cluster
+func MCPRef(ref types.NamespacedName) *unstructured.Unstructured {
+	return internal.UnstructuredRef(ref.Name, ref.Namespace, schema.GroupVersionKind{
+		Group:   "core.openmcp.cloud",
+		Version: "v2alpha1",
+		Kind:    "ManagedControlPlaneV2",
+	})
+}
+
+// CreateMCP creates an MCP object on the onboarding cluster and waits until it is ready.
+// The MCP can be configured with options, e.g. MCPNamespace or MCPUser
+func CreateMCP(name string, timeout time.Duration, opts ...MCPOption) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		spec := NewMCPSpec(name, opts...)
+		spec.resolveNamespace(ctx)
+		ctx = context.WithValue(ctx, mcpNameContextKey{}, name)
+		ctx = context.WithValue(ctx, mcpNamespaceContextKey{name: name}, spec.Namespace)
+		if err := createMCP(ctx, spec, timeout); err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}
+}
+
+// CreateMCPs creates an MCP object for each of the passed in names concurrently and waits until all of them are ready.
+// The options apply to all MCPs. Afterwards the config of each MCP cluster is resolved and available through MCPConfig
+func CreateMCPs(names []string, timeout time.Duration, opts ...MCPOption) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		specs := make([]*MCPSpec, len(names))
+		for i, name := range names {
+			specs[i] = NewMCPSpec(name, opts...)
+			specs[i].resolveNamespace(ctx)
+			ctx = context.WithValue(ctx, mcpNamespaceContextKey{name: name}, specs[i].Namespace)
+		}
+		if len(names) > 0 {
+			ctx = context.WithValue(ctx, mcpNameContextKey{}, names[len(names)-1])
+		}
+		errs := make([]error, len(specs))
+		var wg sync.WaitGroup
+		for i, spec := range specs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = createMCP(ctx, spec, timeout)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			t.Error(err)
+			return ctx
+		}
+		for _, name := range names {
+			mcpCfg, err := resolveMCPConfig(ctx, c, name)
+			if err != nil {
+				t.Errorf("failed to resolve cluster of MCP %s: %v", name, err)
+				continue
+			}
+			ctx = context.WithValue(ctx, mcpConfigContextKey{name: name}, mcpCfg)
+		}
+		return ctx
+	}
+}
+
+func createMCP(ctx context.Context, spec *MCPSpec, timeout time.Duration) error {
+	klog.Infof("create MCP: %s/%s", spec.Namespace, spec.Name)
+	onboardingCfg, err := clusterutils.OnboardingConfigInNamespace(spec.Namespace)
+	if err != nil {
+		return err
+	}
+	// the namespaces of projects and workspaces are owned by openMCP
+	if spec.project == "" {
+		if err := resources.EnsureNamespace(ctx, onboardingCfg, spec.Namespace); err != nil {
+			return err
+		}
+	}
+	var overlay map[string]interface{}
+	if spec.Overrides != nil {
+		overlay = map[string]interface{}{"spec": spec.Overrides}
+	}
+	obj, err := resources.CreateObjectFromTemplate(ctx, onboardingCfg, mcpTemplate, spec, resources.Overlay(overlay))
+	if err != nil {
+		return fmt.Errorf("failed to create MCP %s: %w", spec.Name, err)
+	}
+	if err := wait.For(
+		conditions.Status(obj, onboardingCfg, "phase", "Ready"),
+		wait.WithTimeout(timeout),
+	); err != nil {
+		return fmt.Errorf("MCP %s failed to get ready: %w", spec.Name, err)
+	}
+	return nil
+}
+
+// MCPConfig returns an environment config to work with the cluster of the MCP with the passed in name.
+// Configs resolved by CreateMCPs are taken from the context, otherwise access is requested through an AccessRequest
+// for the ClusterRequest of the MCP on the platform cluster of the passed in config. If no access is granted,
+// the kind cluster of the MCP is looked up instead
+func MCPConfig(ctx context.Context, c *envconf.Config, name string) (*envconf.Config, error) {
+	if mcpCfg, ok := ctx.Value(mcpConfigContextKey{name: name}).(*envconf.Config); ok {
+		return mcpCfg, nil
+	}
+	return resolveMCPConfig(ctx, c, name)
+}
+
+// MCPPlatformNamespace returns the namespace on the platform cluster in which openMCP creates the ClusterRequest
+// of the MCP with the passed in name and onboarding namespace. It follows the naming of the openMCP operator and
+// can be replaced if an openMCP version derives the namespace differently
+var MCPPlatformNamespace = func(name string, namespace string) string {
+	return "mcp--" + uuid.NewSHA1(uuid.Nil, []byte(namespace+"/"+name)).String()
+}
+
+// mcpAccessTimeout bounds the wait for the AccessRequest of an MCP before the kind cluster is looked up instead
+const mcpAccessTimeout = time.Minute
+
+// resolveMCPConfig requests access to the cluster of the ClusterRequest that openMCP creates for an MCP on the platform cluster.
+// If no access is granted, the kind cluster is looked up by the name of the bound Cluster and finally by the "mcp" prefix
+func resolveMCPConfig(ctx context.Context, c *envconf.Config, name string) (*envconf.Config, error) {
+	request := types.NamespacedName{
+		Namespace: MCPPlatformNamespace(name, McpNamespaceFromContext(ctx, name)),
+		Name:      name,
+	}
+	mcpCfg, err := requestMCPAccess(ctx, c, request)
+	if err == nil {
+		return mcpCfg, nil
+	}
+	klog.Infof("no access granted to cluster request %s of MCP %s, looking up the kind cluster: %v", request, name, err)
+	if cluster, err := BoundCluster(ctx, c, request); err == nil {
+		if mcpCfg, err := clusterutils.ConfigByName(cluster.Name, corev1.NamespaceDefault); err == nil {
+			return mcpCfg, nil
+		}
+	}
+	return clusterutils.McpConfig()
+}
+
+// requestMCPAccess requests admin access to the cluster the ClusterRequest of an MCP has been bound to
+func requestMCPAccess(ctx context.Context, c *envconf.Config, request types.NamespacedName) (*envconf.Config, error) {
+	platformCfg := envconf.New().WithClient(c.Client()).WithNamespace(request.Namespace)
+	setup := AccessRequestSetup{
+		Name:    "testing-" + request.Name,
+		Request: &request,
+		Permissions: []Permission{{
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		}},
+	}
+	if err := CreateAccessRequest(ctx, platformCfg, setup); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	ref := types.NamespacedName{Namespace: request.Namespace, Name: setup.Name}
+	return WaitForAccess(ctx, platformCfg, ref, wait.WithTimeout(mcpAccessTimeout))
+}
+
+// UpdateMCP applies the passed in options to an existing MCP on the onboarding cluster and waits until the change
+// has been reconciled, i.e. the MCP is ready again and has observed the new generation.
+// Labels and annotations are added, role bindings and OIDC providers are appended to the existing ones
+// and spec overrides are deep merged into the spec of the MCP.
+// The MCP is looked up in the namespace passed with MCPNamespace, MCPInProject or MCPInWorkspace,
+// otherwise in the namespace it has been created in by CreateMCP within the same feature
+func UpdateMCP(name string, timeout time.Duration, opts ...MCPOption) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		spec := NewMCPSpec(name, opts...)
+		namespace := McpNamespaceFromContext(ctx, name)
+		if spec.placed {
+			spec.resolveNamespace(ctx)
+			namespace = spec.Namespace
+		}
+		klog.Infof("update MCP: %s/%s", namespace, name)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace(namespace)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		obj := MCPRef(types.NamespacedName{Namespace: namespace, Name: name})
+		err = resources.Mutate(ctx, onboardingCfg, obj, func(o k8s.Object) error {
+			return spec.applyTo(o.(*unstructured.Unstructured))
+		})
+		if err != nil {
+			t.Errorf("failed to update MCP %s: %v", name, err)
+			return ctx
+		}
+		if err := wait.For(
+			conditions.PhaseAtGeneration(obj, onboardingCfg, "Ready", obj.GetGeneration()),
+			wait.WithTimeout(timeout),
+		); err != nil {
+			t.Errorf("MCP failed to reconcile generation %d: %v", obj.GetGeneration(), err)
+		}
+		return ctx
+	}
+}
+
+// applyTo adds the labels, annotations, role bindings and OIDC providers to an existing MCP object
+// and deep merges the spec overrides into its spec
+func (s *MCPSpec) applyTo(obj *unstructured.Unstructured) error {
+	if s.Labels != nil {
+		obj.SetLabels(mergeStrings(obj.GetLabels(), s.Labels))
+	}
+	if s.Annotations != nil {
+		obj.SetAnnotations(mergeStrings(obj.GetAnnotations(), s.Annotations))
+	}
+	if oidc := s.IAM.OIDC; oidc != nil {
+		if oidc.DefaultProvider != nil {
+			if err := appendNestedSlice(obj.Object, oidc.DefaultProvider.RoleBindings, "spec", "iam", "oidc", "defaultProvider", "roleBindings"); err != nil {
+				return err
+			}
+		}
+		if err := appendNestedSlice(obj.Object, oidc.ExtraProviders, "spec", "iam", "oidc", "extraProviders"); err != nil {
+			return err
+		}
+	}
+	if s.Overrides == nil {
+		return nil
+	}
+	var overrides map[string]interface{}
+	if err := convertJSON(s.Overrides, &overrides); err != nil {
+		return err
+	}
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		spec = map[string]interface{}{}
+		obj.Object["spec"] = spec
+	}
+	internal.DeepMerge(spec, overrides)
+	return nil
+}
+
+// appendNestedSlice appends the JSON representation of the passed in items to the list at the path of the object
+func appendNestedSlice(obj map[string]interface{}, items interface{}, fields ...string) error {
+	var values []interface{}
+	if err := convertJSON(items, &values); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	existing, _, err := unstructured.NestedSlice(obj, fields...)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(obj, append(existing, values...), fields...)
+}
+
+func convertJSON(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// DeleteMCP deletes the MCP object on the onboarding cluster and waits until the object has been deleted.
+// The MCP is looked up in the namespace it has been created in by CreateMCP within the same feature,
+// otherwise in the default namespace
+func DeleteMCP(name string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		return DeleteMCPInNamespace(name, McpNamespaceFromContext(ctx, name), opts...)(ctx, t, c)
+	}
+}
+
+// DeleteMCPInNamespace deletes the MCP object in the passed in namespace of the onboarding cluster
+// and waits until the object has been deleted
+func DeleteMCPInNamespace(name string, namespace string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		klog.Infof("delete MCP: %s/%s", namespace, name)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace(namespace)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		mcp := MCPRef(types.NamespacedName{
+			Namespace: namespace,
+			Name:      name,
+		})
+		err = resources.DeleteObject(ctx, onboardingCfg, mcp, opts...)
+		if err != nil {
+			t.Errorf("failed to delete MCP %s: %v", name, err)
+			return ctx
+		}
+		return ctx
+	}
+}
@@ -0,0 +1,219 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
+	"github.com/christophrj/openmcp-testing/pkg/conditions"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+const projectTemplate = `
+apiVersion: core.openmcp.cloud/v1alpha1
+kind: Project
+metadata:
+  name: {{.Name}}
+spec:
+{{- with .Members}}
+  members:
+{{- toYaml . | nindent 4}}
+{{- else}} {}
+{{- end}}
+`
+
+const workspaceTemplate = `
+apiVersion: core.openmcp.cloud/v1alpha1
+kind: Workspace
+metadata:
+  name: {{.Name}}
+spec:
+{{- with .Members}}
+  members:
+{{- toYaml . | nindent 4}}
+{{- else}} {}
+{{- end}}
+`
+
+// Roles that can be granted to the members of a project or workspace.
+// openMCP binds each member to the corresponding role in the namespace of the project or workspace
+const (
+	RoleAdmin = "admin"
+	RoleView  = "view"
+)
+
+// Member represents a user, group or service account that is granted roles on a project or workspace
+type Member struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Roles     []string `json:"roles"`
+}
+
+// UserMember returns a member for the passed in user
+func UserMember(name string, roles ...string) Member {
+	return Member{Kind: "User", Name: name, Roles: roles}
+}
+
+// GroupMember returns a member for the passed in group
+func GroupMember(name string, roles ...string) Member {
+	return Member{Kind: "Group", Name: name, Roles: roles}
+}
+
+// ServiceAccountMember returns a member for the passed in service account of the onboarding cluster
+func ServiceAccountMember(name string, namespace string, roles ...string) Member {
+	return Member{Kind: "ServiceAccount", Name: name, Namespace: namespace, Roles: roles}
+}
+
+type tenancyNamespaceContextKey struct {
+	kind    string
+	project string
+	name    string
+}
+
+// ProjectNamespace returns the namespace that openMCP creates on the onboarding cluster for the passed in project
+func ProjectNamespace(project string) string {
+	return "project-" + project
+}
+
+// WorkspaceNamespace returns the namespace that openMCP creates on the onboarding cluster for the passed in workspace of a project
+func WorkspaceNamespace(project string, workspace string) string {
+	return ProjectNamespace(project) + "--ws-" + workspace
+}
+
+// ProjectNamespaceFromContext returns the namespace that has been reported for the passed in project
+// by CreateProject within a feature. Otherwise the namespace is derived from the project name
+func ProjectNamespaceFromContext(ctx context.Context, project string) string {
+	if namespace, ok := ctx.Value(tenancyNamespaceContextKey{kind: "Project", name: project}).(string); ok {
+		return namespace
+	}
+	return ProjectNamespace(project)
+}
+
+// WorkspaceNamespaceFromContext returns the namespace that has been reported for the passed in workspace
+// by CreateWorkspace within a feature. Otherwise the namespace is derived from the project and workspace name
+func WorkspaceNamespaceFromContext(ctx context.Context, project string, workspace string) string {
+	key := tenancyNamespaceContextKey{kind: "Workspace", project: project, name: workspace}
+	if namespace, ok := ctx.Value(key).(string); ok {
+		return namespace
+	}
+	return WorkspaceNamespace(project, workspace)
+}
+
+func projectRef(name string) *unstructured.Unstructured {
+	return internal.UnstructuredRef(name, "", schema.GroupVersionKind{
+		Group:   "core.openmcp.cloud",
+		Version: "v1alpha1",
+		Kind:    "Project",
+	})
+}
+
+func workspaceRef(name string, namespace string) *unstructured.Unstructured {
+	return internal.UnstructuredRef(name, namespace, schema.GroupVersionKind{
+		Group:   "core.openmcp.cloud",
+		Version: "v1alpha1",
+		Kind:    "Workspace",
+	})
+}
+
+// CreateProject creates a project with the passed in members on the onboarding cluster and waits until
+// it is ready and openMCP has created its namespace. The namespace is available through ProjectNamespaceFromContext
+func CreateProject(name string, timeout time.Duration, members ...Member) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		klog.Infof("create project: %s", name)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace("")
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		namespace, err := createTenancyObject(ctx, onboardingCfg, projectTemplate, name, members, timeout)
+		if err != nil {
+			t.Errorf("failed to create project %s: %v", name, err)
+			return ctx
+		}
+		klog.Infof("project %s ready, namespace: %s", name, namespace)
+		return context.WithValue(ctx, tenancyNamespaceContextKey{kind: "Project", name: name}, namespace)
+	}
+}
+
+// CreateWorkspace creates a workspace with the passed in members in the namespace of a project and waits until
+// it is ready and openMCP has created its namespace. The namespace is available through WorkspaceNamespaceFromContext
+func CreateWorkspace(project string, name string, timeout time.Duration, members ...Member) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		klog.Infof("create workspace: %s/%s", project, name)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace(ProjectNamespaceFromContext(ctx, project))
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		namespace, err := createTenancyObject(ctx, onboardingCfg, workspaceTemplate, name, members, timeout)
+		if err != nil {
+			t.Errorf("failed to create workspace %s/%s: %v", project, name, err)
+			return ctx
+		}
+		klog.Infof("workspace %s/%s ready, namespace: %s", project, name, namespace)
+		key := tenancyNamespaceContextKey{kind: "Workspace", project: project, name: name}
+		return context.WithValue(ctx, key, namespace)
+	}
+}
+
+// createTenancyObject creates a project or workspace, waits until it is ready and returns the namespace reported in its status
+func createTenancyObject(ctx context.Context, c *envconf.Config, template string, name string, members []Member, timeout time.Duration) (string, error) {
+	data := struct {
+		Name    string
+		Members []Member
+	}{Name: name, Members: members}
+	obj, err := resources.CreateObjectFromTemplate(ctx, c, template, data)
+	if err != nil {
+		return "", err
+	}
+	if err := wait.For(conditions.Status(obj, c, "phase", "Ready"), wait.WithTimeout(timeout)); err != nil {
+		return "", err
+	}
+	if err := wait.For(conditions.StatusSet(obj, c, "namespace"), wait.WithTimeout(timeout)); err != nil {
+		return "", err
+	}
+	namespace, _, err := unstructured.NestedString(obj.Object, "status", "namespace")
+	return namespace, err
+}
+
+// DeleteProject deletes the project object on the onboarding cluster and waits until the object has been deleted
+func DeleteProject(name string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		klog.Infof("delete project: %s", name)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace("")
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := resources.DeleteObject(ctx, onboardingCfg, projectRef(name), opts...); err != nil {
+			t.Errorf("failed to delete project %s: %v", name, err)
+		}
+		return ctx
+	}
+}
+
+// DeleteWorkspace deletes the workspace object on the onboarding cluster and waits until the object has been deleted
+func DeleteWorkspace(project string, name string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		klog.Infof("delete workspace: %s/%s", project, name)
+		namespace := ProjectNamespaceFromContext(ctx, project)
+		onboardingCfg, err := clusterutils.OnboardingConfigInNamespace(namespace)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := resources.DeleteObject(ctx, onboardingCfg, workspaceRef(name, namespace), opts...); err != nil {
+			t.Errorf("failed to delete workspace %s/%s: %v", project, name, err)
+		}
+		return ctx
+	}
+}
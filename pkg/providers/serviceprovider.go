@@ -2,8 +2,11 @@ package providers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/christophrj/openmcp-testing/internal"
 	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
 	"github.com/christophrj/openmcp-testing/pkg/conditions"
 	"github.com/christophrj/openmcp-testing/pkg/resources"
@@ -22,35 +25,114 @@ kind: ServiceProvider
 metadata:
   name: {{.Name}}
 spec:
-  image: {{.Image}}
-`
+  image: {{.Image}}` + deploymentSpecTemplate
 
 // ServiceProviderSetup represents the configuration parameters to set up a service provider
 type ServiceProviderSetup struct {
 	Name  string
 	Image string
-	Opts  []wait.Option
+	// ImagePullSecrets are the names of the secrets in the openMCP namespace that are used to pull the image
+	ImagePullSecrets []string
+	// InitCommand and RunCommand are passed as arguments to the init and run commands of the provider
+	InitCommand []string
+	RunCommand  []string
+	// Env are the environment variables of the provider deployment
+	Env []EnvVar
+	// Verbosity is the log level of the provider, e.g. DEBUG
+	Verbosity         string
+	ExtraVolumes      []corev1.Volume
+	ExtraVolumeMounts []corev1.VolumeMount
+	// Overlay is deep merged into the rendered ServiceProvider object,
+	// e.g. {"spec": {...}} for provider specific spec fields
+	Overlay map[string]interface{}
+	// Manifests are additional objects, e.g. provider configurations, that are applied to the platform cluster
+	// once the provider is ready. Each manifest may contain multiple documents and is rendered as a template with the setup.
+	// The objects are labeled with ServiceProviderLabel so that UninstallServiceProvider can find them
+	Manifests []string
+	// ManifestFiles are template files that are rendered and applied like Manifests
+	ManifestFiles []string
+	Opts          []wait.Option
 }
 
-func serviceProviderRef(name string) *unstructured.Unstructured {
-	obj := &unstructured.Unstructured{}
-	obj.SetName(name)
-	obj.SetGroupVersionKind(schema.GroupVersionKind{
+// ServiceProviderLabel is set on the additional manifests of a service provider and holds the name of the provider
+const ServiceProviderLabel = "testing.openmcp.cloud/service-provider"
+
+// ServiceProviderRef returns a reference to the ServiceProvider object with the passed in name on the platform cluster
+func ServiceProviderRef(name string) *unstructured.Unstructured {
+	return internal.UnstructuredRef(name, "", schema.GroupVersionKind{
 		Group:   "openmcp.cloud",
 		Version: "v1alpha1",
 		Kind:    "ServiceProvider",
 	})
-	return obj
 }
 
-// InstallServiceProvider creates a service provider object on the platform cluster and waits until it is ready
+// InstallServiceProvider creates a service provider object on the platform cluster and waits until it is ready.
+// Afterwards the additional manifests of the setup are applied to the platform cluster
 func InstallServiceProvider(ctx context.Context, c *envconf.Config, sp ServiceProviderSetup) error {
 	klog.Infof("create service provider: %s", sp.Name)
-	obj, err := resources.CreateObjectFromTemplate(ctx, c, serviceProviderTemplate, sp)
+	obj, err := resources.CreateObjectFromTemplate(ctx, c, serviceProviderTemplate, sp, resources.Overlay(sp.Overlay))
 	if err != nil {
 		return err
 	}
-	return wait.For(conditions.Match(obj, c, "Ready", corev1.ConditionTrue), sp.Opts...)
+	if err := wait.For(conditions.Match(obj, c, "Ready", corev1.ConditionTrue), sp.Opts...); err != nil {
+		return err
+	}
+	return applyManifests(ctx, c, sp)
+}
+
+func applyManifests(ctx context.Context, c *envconf.Config, sp ServiceProviderSetup) error {
+	opts := []resources.Option{
+		resources.ServerSideApply(resources.DefaultFieldManager),
+		resources.ForceConflicts(),
+		resources.WaitOptions(sp.Opts...),
+		resources.Labels(map[string]string{ServiceProviderLabel: sp.Name}),
+	}
+	for _, manifest := range sp.Manifests {
+		if _, err := resources.CreateObjectsFromTemplate(ctx, c, manifest, sp, opts...); err != nil {
+			return fmt.Errorf("failed to apply manifest of service provider %s: %w", sp.Name, err)
+		}
+	}
+	for _, file := range sp.ManifestFiles {
+		if _, err := resources.CreateObjectsFromTemplateFile(ctx, c, file, sp, opts...); err != nil {
+			return fmt.Errorf("failed to apply manifest file %s of service provider %s: %w", file, sp.Name, err)
+		}
+	}
+	return nil
+}
+
+const (
+	onboardingCluster = "onboarding"
+	mcpCluster        = "mcp"
+)
+
+// namedMcpCluster identifies the cluster of a specific MCP when recording imported objects
+func namedMcpCluster(mcpName string) string {
+	return mcpCluster + "/" + mcpName
+}
+
+type importContextKey struct {
+	cluster string
+	source  string
+}
+
+// withImportedObjects records the objects that have been imported from a source to a cluster in the context
+func withImportedObjects(ctx context.Context, cluster string, source string, objList *unstructured.UnstructuredList) context.Context {
+	if objList == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, importContextKey{cluster: cluster, source: source}, objList)
+}
+
+func deleteImportedObjects(ctx context.Context, t *testing.T, c *envconf.Config, cluster string, source string, opts ...wait.Option) {
+	objList, ok := ctx.Value(importContextKey{cluster: cluster, source: source}).(*unstructured.UnstructuredList)
+	if !ok {
+		klog.Infof("no objects have been imported from %s to the %s cluster", source, cluster)
+		return
+	}
+	// objects that existed before the import are left to their owner
+	if err := resources.DeleteObjects(ctx, c, resources.CreatedByRun(objList), opts...); err != nil {
+		t.Errorf("failed to delete objects imported from %s: %v", source, err)
+	}
 }
 
 // ImportServiceProviderAPIs iterates over each resource from the passed in directory
@@ -58,10 +140,11 @@ func InstallServiceProvider(ctx context.Context, c *envconf.Config, sp ServicePr
 func ImportServiceProviderAPIs(directory string, opts ...wait.Option) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
 		klog.Infof("apply service provider resources to onboarding cluster from %s ...", directory)
-		if _, err := clusterutils.ImportToOnboardingCluster(ctx, directory, opts...); err != nil {
+		objList, err := clusterutils.ImportToOnboardingCluster(ctx, directory, opts...)
+		if err != nil {
 			t.Error(err)
 		}
-		return ctx
+		return withImportedObjects(ctx, onboardingCluster, directory, objList)
 	}
 }
 
@@ -70,15 +153,218 @@ func ImportServiceProviderAPIs(directory string, opts ...wait.Option) features.F
 func ImportDomainAPIs(directory string, opts ...wait.Option) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
 		klog.Infof("apply service provider resources to MCP cluster from %s ...", directory)
-		if _, err := clusterutils.ImportToMcpCluster(ctx, directory, opts...); err != nil {
+		objList, err := clusterutils.ImportToMcpCluster(ctx, directory, opts...)
+		if err != nil {
+			t.Error(err)
+		}
+		return withImportedObjects(ctx, mcpCluster, directory, objList)
+	}
+}
+
+// ImportDomainAPIsToMCP iterates over each resource from the passed in directory
+// and applies it to the cluster of the MCP with the passed in name, see MCPConfig
+func ImportDomainAPIsToMCP(mcpName string, directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("apply service provider resources to cluster of MCP %s from %s ...", mcpName, directory)
+		c, err := MCPConfig(ctx, cfg, mcpName)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		objList, err := clusterutils.ImportToCluster(ctx, c, directory, opts...)
+		if err != nil {
+			t.Error(err)
+		}
+		return withImportedObjects(ctx, namedMcpCluster(mcpName), directory, objList)
+	}
+}
+
+// ImportServiceProviderKustomization builds the kustomization at the passed in path
+// and applies the resulting resources to the onboarding cluster
+func ImportServiceProviderKustomization(path string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("apply service provider kustomization to onboarding cluster from %s ...", path)
+		objList, err := clusterutils.ImportKustomizationToOnboardingCluster(ctx, path, opts...)
+		if err != nil {
+			t.Error(err)
+		}
+		return withImportedObjects(ctx, onboardingCluster, path, objList)
+	}
+}
+
+// ImportDomainKustomization builds the kustomization at the passed in path
+// and applies the resulting resources to a MCP cluster
+func ImportDomainKustomization(path string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("apply service provider kustomization to MCP cluster from %s ...", path)
+		objList, err := clusterutils.ImportKustomizationToMcpCluster(ctx, path, opts...)
+		if err != nil {
+			t.Error(err)
+		}
+		return withImportedObjects(ctx, mcpCluster, path, objList)
+	}
+}
+
+// TemplateData represents the values that are available to templated imports.
+// The standard values are filled in automatically, Data holds the values supplied by the test
+type TemplateData struct {
+	// McpName is the name of the MCP that has been created last within the feature
+	McpName string
+	// PlatformNamespace is the namespace of the openMCP installation on the platform cluster
+	PlatformNamespace string
+	// RunID identifies the current test run
+	RunID string
+	// Data is the custom data passed in by the test
+	Data interface{}
+}
+
+func newTemplateData(ctx context.Context, cfg *envconf.Config, data interface{}) TemplateData {
+	return TemplateData{
+		McpName:           McpNameFromContext(ctx),
+		PlatformNamespace: cfg.Namespace(),
+		RunID:             resources.RunID(),
+		Data:              data,
+	}
+}
+
+// ImportServiceProviderAPIsFromTemplates renders each file of the passed in directory as a template
+// with TemplateData and applies the resulting resources to the onboarding cluster
+func ImportServiceProviderAPIsFromTemplates(directory string, data interface{}, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("apply service provider templates to onboarding cluster from %s ...", directory)
+		objList, err := clusterutils.ImportTemplatesToOnboardingCluster(ctx, directory, newTemplateData(ctx, cfg, data), opts...)
+		if err != nil {
 			t.Error(err)
 		}
+		return withImportedObjects(ctx, onboardingCluster, directory, objList)
+	}
+}
+
+// ImportDomainAPIsFromTemplates renders each file of the passed in directory as a template
+// with TemplateData and applies the resulting resources to a MCP cluster
+func ImportDomainAPIsFromTemplates(directory string, data interface{}, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("apply service provider templates to MCP cluster from %s ...", directory)
+		objList, err := clusterutils.ImportTemplatesToMcpCluster(ctx, directory, newTemplateData(ctx, cfg, data), opts...)
+		if err != nil {
+			t.Error(err)
+		}
+		return withImportedObjects(ctx, mcpCluster, directory, objList)
+	}
+}
+
+// AssertServiceProviderAPIs checks that the objects specified by the files of the passed in directory
+// exist on the onboarding cluster and contain all specified fields
+func AssertServiceProviderAPIs(directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		c, err := clusterutils.OnboardingConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := resources.AssertObjectsFromDir(ctx, c, directory, resources.WaitOptions(opts...)); err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}
+}
+
+// AssertDomainAPIs checks that the objects specified by the files of the passed in directory
+// exist on a MCP cluster and contain all specified fields
+func AssertDomainAPIs(directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		c, err := clusterutils.McpConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := resources.AssertObjectsFromDir(ctx, c, directory, resources.WaitOptions(opts...)); err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}
+}
+
+// AssertDomainAPIsOnMCP checks that the objects specified by the files of the passed in directory
+// exist on the cluster of the MCP with the passed in name and contain all specified fields
+func AssertDomainAPIsOnMCP(mcpName string, directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		c, err := MCPConfig(ctx, cfg, mcpName)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := resources.AssertObjectsFromDir(ctx, c, directory, resources.WaitOptions(opts...)); err != nil {
+			t.Errorf("MCP %s: %v", mcpName, err)
+		}
+		return ctx
+	}
+}
+
+// DeleteServiceProviderAPIs deletes the objects that have been imported to the onboarding cluster
+// from the passed in directory or kustomization path within the same feature
+func DeleteServiceProviderAPIs(directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("delete service provider resources imported from %s from onboarding cluster ...", directory)
+		c, err := clusterutils.OnboardingConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		deleteImportedObjects(ctx, t, c, onboardingCluster, directory, opts...)
+		return ctx
+	}
+}
+
+// DeleteDomainAPIs deletes the objects that have been imported to a MCP cluster
+// from the passed in directory or kustomization path within the same feature
+func DeleteDomainAPIs(directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("delete service provider resources imported from %s from MCP cluster ...", directory)
+		c, err := clusterutils.McpConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		deleteImportedObjects(ctx, t, c, mcpCluster, directory, opts...)
+		return ctx
+	}
+}
+
+// DeleteDomainAPIsFromMCP deletes the objects that have been imported to the cluster of the MCP with the passed in name
+// from the passed in directory within the same feature
+func DeleteDomainAPIsFromMCP(mcpName string, directory string, opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.Infof("delete service provider resources imported from %s from cluster of MCP %s ...", directory, mcpName)
+		c, err := MCPConfig(ctx, cfg, mcpName)
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		deleteImportedObjects(ctx, t, c, namedMcpCluster(mcpName), directory, opts...)
 		return ctx
 	}
 }
 
-// DeleteServiceProvider deletes the service provider object on the platform cluster and waits until the object has been deleted
+// UninstallServiceProvider deletes the additional manifests that have been created by InstallServiceProvider for the setup
+// and the service provider object on the platform cluster and waits until the objects have been deleted.
+// The manifests are selected by the ServiceProviderLabel and the run ID label, so that existing objects are left alone
+func UninstallServiceProvider(ctx context.Context, c *envconf.Config, sp ServiceProviderSetup, opts ...wait.Option) error {
+	var errs []error
+	if len(sp.Manifests) > 0 || len(sp.ManifestFiles) > 0 {
+		klog.Infof("delete manifests of service provider: %s", sp.Name)
+		selector := fmt.Sprintf("%s=%s,%s=%s", ServiceProviderLabel, sp.Name, resources.RunIDLabel, resources.RunID())
+		if err := resources.DeleteObjectsWithLabels(ctx, c, selector, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete manifests of service provider %s: %w", sp.Name, err))
+		}
+	}
+	errs = append(errs, DeleteServiceProvider(ctx, c, sp.Name, opts...))
+	return errors.Join(errs...)
+}
+
+// DeleteServiceProvider deletes the service provider object on the platform cluster and waits until the object has been deleted.
+// Use UninstallServiceProvider to delete the additional manifests of a setup as well
 func DeleteServiceProvider(ctx context.Context, c *envconf.Config, name string, opts ...wait.Option) error {
 	klog.Infof("delete service provider: %s", name)
-	return resources.DeleteObject(ctx, c, serviceProviderRef(name), opts...)
+	return resources.DeleteObject(ctx, c, ServiceProviderRef(name), opts...)
 }
@@ -0,0 +1,249 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// AssertObjects checks that each object of the manifest exists on the cluster and contains all fields
+// that are specified in the manifest. Fields that are not specified are ignored. Lists match if each expected
+// element is contained in the actual list, regardless of the order.
+// If WaitOptions are passed, the check is repeated until it succeeds or the wait times out.
+// The returned error contains a diff of all mismatches
+func AssertObjects(ctx context.Context, cfg *envconf.Config, manifest string, opts ...Option) error {
+	expected, err := decodeUnstructured(strings.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	return assertObjects(ctx, cfg, expected, newOptions(opts))
+}
+
+// AssertObjectsFromDir checks that the objects specified by the files of a directory match the objects on the cluster.
+// See AssertObjects for the matching rules and CreateObjectsFromFS for the options to select files
+func AssertObjectsFromDir(ctx context.Context, cfg *envconf.Config, dir string, opts ...Option) error {
+	return AssertObjectsFromFS(ctx, cfg, os.DirFS(dir), opts...)
+}
+
+// AssertObjectsFromFS checks that the objects specified by the files of a file system match the objects on the cluster.
+// See AssertObjects for the matching rules and CreateObjectsFromFS for the options to select files
+func AssertObjectsFromFS(ctx context.Context, cfg *envconf.Config, fsys fs.FS, opts ...Option) error {
+	o := newOptions(opts)
+	expected := []*unstructured.Unstructured{}
+	err := readFS(fsys, o, func(p string, manifest []byte) error {
+		objs, err := decodeUnstructured(bytes.NewReader(manifest))
+		if err != nil {
+			return fmt.Errorf("failed to decode file %q: %w", p, err)
+		}
+		expected = append(expected, objs...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return assertObjects(ctx, cfg, expected, o)
+}
+
+func assertObjects(ctx context.Context, cfg *envconf.Config, expected []*unstructured.Unstructured, o *options) error {
+	for _, obj := range expected {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(cfg.Namespace())
+		}
+	}
+	if o.waitOpts == nil {
+		return compareObjects(ctx, cfg, expected)
+	}
+	var mismatch error
+	err := wait.For(func(ctx context.Context) (bool, error) {
+		mismatch = compareObjects(ctx, cfg, expected)
+		return mismatch == nil, nil
+	}, o.waitOpts...)
+	if err != nil && mismatch != nil {
+		return mismatch
+	}
+	return err
+}
+
+// compareObjects returns an error listing the differences between the expected and the actual objects
+func compareObjects(ctx context.Context, cfg *envconf.Config, expected []*unstructured.Unstructured) error {
+	var errs []error
+	for _, exp := range expected {
+		actual := &unstructured.Unstructured{}
+		actual.SetGroupVersionKind(exp.GroupVersionKind())
+		header := fmt.Sprintf("%s %s/%s", exp.GetKind(), exp.GetNamespace(), exp.GetName())
+		if err := cfg.Client().Resources().Get(ctx, exp.GetName(), exp.GetNamespace(), actual); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", header, err))
+			continue
+		}
+		if diff := subsetDiff("", exp.Object, actual.Object); len(diff) > 0 {
+			errs = append(errs, fmt.Errorf("%s:\n  %s", header, strings.Join(diff, "\n  ")))
+		}
+	}
+	if len(errs) > 0 {
+		klog.Infof("objects do not match expectation: %v", errors.Join(errs...))
+		return fmt.Errorf("objects do not match expectation:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// subsetDiff returns a line for each field of expected that is missing or different in actual
+func subsetDiff(path string, expected interface{}, actual interface{}) []string {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %s", fmtPath(path), fmtValue(actual))}
+		}
+		keys := make([]string, 0, len(exp))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var diff []string
+		for _, key := range keys {
+			value := exp[key]
+			actValue, found := act[key]
+			if !found {
+				diff = append(diff, fmt.Sprintf("%s.%s: missing, expected %s", path, key, fmtValue(value)))
+				continue
+			}
+			diff = append(diff, subsetDiff(path+"."+key, value, actValue)...)
+		}
+		return diff
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected list, got %s", fmtPath(path), fmtValue(actual))}
+		}
+		var diff []string
+		for i, matched := range matchElements(exp, act) {
+			if !matched {
+				diff = append(diff, fmt.Sprintf("%s[%d]: no matching element for %s", fmtPath(path), i, fmtValue(exp[i])))
+			}
+		}
+		return diff
+	default:
+		if !scalarEqual(expected, actual) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", fmtPath(path), fmtValue(expected), fmtValue(actual))}
+		}
+		return nil
+	}
+}
+
+// matchElements assigns each expected element to a distinct actual element that contains it and returns
+// which expected elements have been assigned. The assignment is a maximum bipartite matching,
+// so that an element that matches several actual elements does not take the only match of another one
+func matchElements(expected []interface{}, actual []interface{}) []bool {
+	candidates := make([][]int, len(expected))
+	for i, value := range expected {
+		for j, element := range actual {
+			if len(subsetDiff("", value, element)) == 0 {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+	}
+	// owner holds the index of the expected element an actual element is assigned to, or -1
+	owner := make([]int, len(actual))
+	for j := range owner {
+		owner[j] = -1
+	}
+	var assign func(i int, visited []bool) bool
+	assign = func(i int, visited []bool) bool {
+		for _, j := range candidates[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if owner[j] == -1 || assign(owner[j], visited) {
+				owner[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	matched := make([]bool, len(expected))
+	for i := range expected {
+		matched[i] = assign(i, make([]bool, len(actual)))
+	}
+	return matched
+}
+
+func scalarEqual(expected interface{}, actual interface{}) bool {
+	if reflect.DeepEqual(expected, actual) {
+		return true
+	}
+	// numbers may be decoded as int64 or float64 depending on their representation
+	exp, ok := toFloat(expected)
+	if !ok {
+		return false
+	}
+	act, ok := toFloat(actual)
+	return ok && exp == act
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func fmtPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func fmtValue(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// decodeUnstructured decodes a stream of YAML or JSON documents into unstructured objects,
+// without adding the default values of typed objects
+func decodeUnstructured(r io.Reader) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	d := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := d.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+}
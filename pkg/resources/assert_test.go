@@ -0,0 +1,97 @@
+package resources
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubsetDiff(t *testing.T) {
+	actual := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"ratio":    float64(0.5),
+			"enabled":  true,
+			"template": map[string]interface{}{
+				"labels": map[string]interface{}{"app": "web", "tier": "frontend"},
+			},
+			"ports": []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(80)},
+				map[string]interface{}{"name": "https", "port": int64(443)},
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(80)},
+				map[string]interface{}{"name": "x", "port": int64(80)},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		expected map[string]interface{}
+		want     []string
+	}{
+		{
+			name:     "nested maps",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}}}},
+		},
+		{
+			name: "unordered lists",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{
+				map[string]interface{}{"port": int64(443)},
+				map[string]interface{}{"name": "http"},
+			}}},
+		},
+		{
+			name: "list elements matching several actual elements",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{
+				map[string]interface{}{"name": "http"},
+				map[string]interface{}{"name": "https"},
+				map[string]interface{}{},
+			}}},
+			want: []string{".spec.ports[2]: no matching element for map[]"},
+		},
+		{
+			name: "list elements that need backtracking",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"endpoints": []interface{}{
+				map[string]interface{}{"port": int64(80)},
+				map[string]interface{}{"name": "http"},
+			}}},
+		},
+		{
+			name:     "numbers decoded as float",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(2)}},
+		},
+		{
+			name:     "missing key",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"paused": false}},
+			want:     []string{".spec.paused: missing, expected false"},
+		},
+		{
+			name:     "unmatched list element",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(8080)}}}},
+			want:     []string{".spec.ports[0]: no matching element for map[port:8080]"},
+		},
+		{
+			name:     "string does not match number",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": "2"}},
+			want:     []string{`.spec.replicas: expected "2", got 2`},
+		},
+		{
+			name:     "string does not match bool",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"enabled": "true"}},
+			want:     []string{`.spec.enabled: expected "true", got true`},
+		},
+		{
+			name:     "object expected",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": map[string]interface{}{"min": int64(1)}}},
+			want:     []string{".spec.replicas: expected object, got 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subsetDiff("", tt.expected, actual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// ForceDeleteTimeout is the time objects are given to be removed before their finalizers are stripped,
+// if no wait options are passed to one of the force delete functions
+const ForceDeleteTimeout = 30 * time.Second
+
+// ForceDeleteObject deletes the passed in object like DeleteObject. If the object has not been removed
+// within the passed in wait options or ForceDeleteTimeout, its finalizers are stripped so that it is removed regardless of them.
+// Only use this in teardown paths where leaking objects is worse than skipping the cleanup of finalizers
+func ForceDeleteObject(ctx context.Context, c *envconf.Config, obj k8s.Object, options ...wait.Option) error {
+	return deleteObject(ctx, c, obj, true, options...)
+}
+
+// ForceDeleteObjects deletes the passed in objects like DeleteObjects, but strips the finalizers
+// of each object that has not been removed within the passed in wait options or ForceDeleteTimeout like ForceDeleteObject
+func ForceDeleteObjects(ctx context.Context, c *envconf.Config, list *unstructured.UnstructuredList, options ...wait.Option) error {
+	return deleteObjects(ctx, c, list, true, options...)
+}
+
+// awaitDeletion waits for the removal of an object that is being deleted if wait options are passed.
+// In force mode the removal is always awaited, by default for ForceDeleteTimeout, so that controllers can run
+// their finalizers. Only the finalizers of an object that has not been removed within that time are stripped
+func awaitDeletion(ctx context.Context, c *envconf.Config, obj k8s.Object, force bool, options ...wait.Option) error {
+	if options == nil {
+		if !force {
+			return nil
+		}
+		options = []wait.Option{wait.WithTimeout(ForceDeleteTimeout)}
+	}
+	err := waitForDeletion(ctx, c, obj, options...)
+	if err == nil || !force {
+		return err
+	}
+	klog.Infof("force delete %s: %v", fmtObj(obj), err)
+	if err := removeFinalizers(ctx, c, obj); err != nil {
+		return internal.IgnoreNotFound(err)
+	}
+	return waitForDeletion(ctx, c, obj, options...)
+}
+
+// waitForDeletion waits until the object has been removed.
+// If the object is still present afterwards, the error describes what blocks the deletion
+func waitForDeletion(ctx context.Context, c *envconf.Config, obj k8s.Object, options ...wait.Option) error {
+	if err := wait.For(conditions.New(c.Client().Resources()).ResourceDeleted(obj), options...); err != nil {
+		return fmt.Errorf("%s was not deleted: %w; %s", fmtObj(obj), err, describeDeletion(ctx, c, obj))
+	}
+	return nil
+}
+
+// describeDeletion returns the remaining finalizers, the deletion timestamp and the conditions of an object
+func describeDeletion(ctx context.Context, c *envconf.Config, obj k8s.Object) string {
+	if err := c.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj); err != nil {
+		return fmt.Sprintf("failed to retrieve object: %v", err)
+	}
+	b := strings.Builder{}
+	deletionTimestamp := "<none>"
+	if ts := obj.GetDeletionTimestamp(); ts != nil {
+		deletionTimestamp = ts.String()
+	}
+	fmt.Fprintf(&b, "deletionTimestamp: %s, finalizers: %v", deletionTimestamp, obj.GetFinalizers())
+	u, err := internal.ToUnstructured(obj)
+	if err != nil {
+		return b.String()
+	}
+	conds, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, condition := range conds {
+		cond, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, ", condition %v=%v (reason: %v, message: %v)", cond["type"], cond["status"], cond["reason"], cond["message"])
+	}
+	return b.String()
+}
+
+func removeFinalizers(ctx context.Context, c *envconf.Config, obj k8s.Object) error {
+	if err := c.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj); err != nil {
+		return err
+	}
+	if len(obj.GetFinalizers()) == 0 {
+		return nil
+	}
+	klog.Infof("%s: removing finalizers %v", fmtObj(obj), obj.GetFinalizers())
+	patch := k8s.Patch{PatchType: types.MergePatchType, Data: []byte(`{"metadata":{"finalizers":null}}`)}
+	return c.Client().Resources().Patch(ctx, obj, patch)
+}
+
+func fmtObj(obj k8s.Object) string {
+	return fmt.Sprintf("object (%s) %s/%s", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,128 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/christophrj/openmcp-testing/pkg/templates"
+	"sigs.k8s.io/e2e-framework/klient/decoder"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+)
+
+// DefaultIncludes are the file patterns that are imported if no include patterns are configured
+var DefaultIncludes = []string{"*.yaml", "*.yml", "*.json"}
+
+// Recursive imports files from all subdirectories instead of only the top level
+func Recursive() Option {
+	return func(o *options) {
+		o.recursive = true
+	}
+}
+
+// Include only imports files whose name or path matches one of the passed in glob patterns.
+// If no include patterns are configured, DefaultIncludes is used
+func Include(patterns ...string) Option {
+	return func(o *options) {
+		o.includes = append(o.includes, patterns...)
+	}
+}
+
+// Exclude skips files and directories whose name or path matches one of the passed in glob patterns
+func Exclude(patterns ...string) Option {
+	return func(o *options) {
+		o.excludes = append(o.excludes, patterns...)
+	}
+}
+
+// Template renders each file as a Go template with the passed in data before it is decoded
+func Template(data interface{}) Option {
+	return func(o *options) {
+		o.templated = true
+		o.templateData = data
+	}
+}
+
+// TemplateOptions configures how templates are rendered, e.g. to make included templates available
+func TemplateOptions(opts ...templates.Option) Option {
+	return func(o *options) {
+		o.templateOpts = append(o.templateOpts, opts...)
+	}
+}
+
+// decodeFS decodes the manifests of each file of the file system that matches the configured patterns
+func decodeFS(ctx context.Context, fsys fs.FS, o *options, decodeOpts ...decoder.DecodeOption) ([]k8s.Object, error) {
+	objs := []k8s.Object{}
+	err := readFS(fsys, o, func(p string, manifest []byte) error {
+		err := decoder.DecodeEach(ctx, bytes.NewReader(manifest), func(ctx context.Context, obj k8s.Object) error {
+			objs = append(objs, obj)
+			return nil
+		}, decodeOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to decode file %q: %w", p, err)
+		}
+		return nil
+	})
+	return objs, err
+}
+
+// readFS passes the content of each file of the file system that matches the configured patterns to fn.
+// Templated files are rendered before they are passed on
+func readFS(fsys fs.FS, o *options, fn func(p string, manifest []byte) error) error {
+	includes := o.includes
+	if len(includes) == 0 {
+		includes = DefaultIncludes
+	}
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		excluded, err := matchesAny(p, o.excludes)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if excluded || !o.recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		included, err := matchesAny(p, includes)
+		if err != nil || excluded || !included {
+			return err
+		}
+		manifest, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if o.templated {
+			rendered, err := templates.Exec(string(manifest), o.templateData, o.templateOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to render template %q: %w", p, err)
+			}
+			manifest = []byte(rendered)
+		}
+		return fn(p, manifest)
+	})
+}
+
+// matchesAny returns true if either the base name or the full path matches one of the patterns
+func matchesAny(p string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		for _, name := range []string{path.Base(p), p} {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
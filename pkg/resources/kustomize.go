@@ -0,0 +1,87 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/e2e-framework/klient/decoder"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// CreateObjectsFromKustomization builds the kustomization at the passed in path and creates the resulting objects.
+// The kustomization is built in process, the objects are created the same way as by CreateObjectsFromDir.
+// Namespaces set by the kustomization are kept, only namespaced objects without a namespace get the namespace of the config
+func CreateObjectsFromKustomization(ctx context.Context, cfg *envconf.Config, path string, opts ...Option) (*unstructured.UnstructuredList, error) {
+	manifest, err := buildKustomization(path)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := decoder.DecodeAll(ctx, bytes.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultNamespace(cfg, objs); err != nil {
+		return nil, err
+	}
+	return createObjects(ctx, cfg, objs, newOptions(opts))
+}
+
+// defaultNamespace sets the namespace of the config on namespaced objects that do not specify a namespace.
+// The scope of custom resources whose CustomResourceDefinition is part of objs is taken from the definition
+func defaultNamespace(cfg *envconf.Config, objs []k8s.Object) error {
+	crdScopes := map[schema.GroupKind]string{}
+	for _, obj := range objs {
+		if !isCRD(obj) {
+			continue
+		}
+		u, err := internal.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+		scope, _, _ := unstructured.NestedString(u.Object, "spec", "scope")
+		crdScopes[schema.GroupKind{Group: group, Kind: kind}] = scope
+	}
+	for _, obj := range objs {
+		if obj.GetNamespace() != "" {
+			continue
+		}
+		namespaced, err := isNamespaced(cfg, obj, crdScopes)
+		if err != nil {
+			return err
+		}
+		if namespaced {
+			obj.SetNamespace(cfg.Namespace())
+		}
+	}
+	return nil
+}
+
+func isNamespaced(cfg *envconf.Config, obj k8s.Object, crdScopes map[schema.GroupKind]string) (bool, error) {
+	if scope, ok := crdScopes[obj.GetObjectKind().GroupVersionKind().GroupKind()]; ok {
+		return scope == string(apiextensionsv1.NamespaceScoped), nil
+	}
+	namespaced, err := cfg.Client().Resources().GetControllerRuntimeClient().IsObjectNamespaced(obj)
+	if err != nil {
+		return false, fmt.Errorf("failed to determine the scope of %s: %w", fmtObj(obj), err)
+	}
+	return namespaced, nil
+}
+
+func buildKustomization(path string) ([]byte, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kustomization %s: %w", path, err)
+	}
+	return resMap.AsYaml()
+}
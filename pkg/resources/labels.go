@@ -0,0 +1,190 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+)
+
+const (
+	// RunIDLabel is set on every object created through this library and identifies the test run.
+	// Existing objects that are applied or skipped are not labeled
+	RunIDLabel = "testing.openmcp.cloud/run-id"
+	// TestNameAnnotation identifies the test that created an object
+	TestNameAnnotation = "testing.openmcp.cloud/test"
+	// FeatureNameAnnotation identifies the feature that created an object
+	FeatureNameAnnotation = "testing.openmcp.cloud/feature"
+)
+
+type stampContextKey struct{}
+
+type stamp struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// Labels adds the passed in labels to every created object
+func Labels(labels map[string]string) Option {
+	return func(o *options) {
+		o.labels = merge(o.labels, labels)
+	}
+}
+
+// Annotations adds the passed in annotations to every created object
+func Annotations(annotations map[string]string) Option {
+	return func(o *options) {
+		o.annotations = merge(o.annotations, annotations)
+	}
+}
+
+// WithLabels returns a context which adds the passed in labels to every object created with it
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	s := stampFromContext(ctx)
+	return context.WithValue(ctx, stampContextKey{}, stamp{labels: merge(s.labels, labels), annotations: s.annotations})
+}
+
+// WithAnnotations returns a context which adds the passed in annotations to every object created with it
+func WithAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	s := stampFromContext(ctx)
+	return context.WithValue(ctx, stampContextKey{}, stamp{labels: s.labels, annotations: merge(s.annotations, annotations)})
+}
+
+// AnnotateFeatureObjects annotates every object created within a feature with the names of the test
+// and the feature when registered with env.BeforeEachFeature
+func AnnotateFeatureObjects() types.FeatureEnvFunc {
+	return func(ctx context.Context, c *envconf.Config, t *testing.T, f types.Feature) (context.Context, error) {
+		return WithAnnotations(ctx, map[string]string{
+			TestNameAnnotation:    t.Name(),
+			FeatureNameAnnotation: f.Name(),
+		}), nil
+	}
+}
+
+func stampFromContext(ctx context.Context) stamp {
+	s, _ := ctx.Value(stampContextKey{}).(stamp)
+	return s
+}
+
+// stampObject sets the labels and annotations of the context and the options on the object
+func stampObject(ctx context.Context, obj k8s.Object, o *options) {
+	s := stampFromContext(ctx)
+	if labels := merge(obj.GetLabels(), s.labels, o.labels); len(labels) > 0 {
+		obj.SetLabels(labels)
+	}
+	if annotations := merge(obj.GetAnnotations(), s.annotations, o.annotations); len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// labelRunID sets the run ID label on an object that is created by the test run.
+// Existing objects are not labeled, so that SweepRun leaves them alone
+func labelRunID(obj k8s.Object) {
+	obj.SetLabels(merge(obj.GetLabels(), map[string]string{RunIDLabel: RunID()}))
+}
+
+// CreatedByRun returns the objects of the list that carry the run ID label of the current test run,
+// i.e. the objects that have been created by the run in contrast to existing objects that have been applied
+func CreatedByRun(list *unstructured.UnstructuredList) *unstructured.UnstructuredList {
+	created := &unstructured.UnstructuredList{}
+	if list == nil {
+		return created
+	}
+	for _, obj := range list.Items {
+		if obj.GetLabels()[RunIDLabel] == RunID() {
+			created.Items = append(created.Items, obj)
+		}
+	}
+	return created
+}
+
+func merge(maps ...map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// SweepRun deletes all objects of the cluster that carry the run ID label with the passed in run ID.
+// This can be used to clean up clusters after a crashed test run
+func SweepRun(ctx context.Context, c *envconf.Config, runID string, options ...wait.Option) error {
+	klog.Infof("sweeping objects of run %s", runID)
+	return DeleteObjectsWithLabels(ctx, c, fmt.Sprintf("%s=%s", RunIDLabel, runID), options...)
+}
+
+// DeleteObjectsWithLabels deletes all objects of the cluster that match the passed in label selector like DeleteObjects
+func DeleteObjectsWithLabels(ctx context.Context, c *envconf.Config, selector string, options ...wait.Option) error {
+	gvks, err := deletableKinds(c)
+	if err != nil {
+		return err
+	}
+	list := &unstructured.UnstructuredList{}
+	for _, gvk := range gvks {
+		objs := &unstructured.UnstructuredList{}
+		objs.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.Client().Resources().List(ctx, objs, resources.WithLabelSelector(selector)); err != nil {
+			return fmt.Errorf("failed to list %s: %w", gvk, err)
+		}
+		for _, obj := range objs.Items {
+			obj.SetGroupVersionKind(gvk)
+			list.Items = append(list.Items, obj)
+		}
+	}
+	klog.Infof("deleting %d objects with labels %s", len(list.Items), selector)
+	return DeleteObjects(ctx, c, list, options...)
+}
+
+// deletableKinds returns the preferred version of each kind of the cluster that can be listed and deleted
+func deletableKinds(c *envconf.Config) ([]schema.GroupVersionKind, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(c.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	resourceLists, err := dc.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	var gvks []schema.GroupVersionKind
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resourceList.APIResources {
+			if strings.Contains(r.Name, "/") || !hasVerbs(r.Verbs, "list", "delete") {
+				continue
+			}
+			gvks = append(gvks, gv.WithKind(r.Kind))
+		}
+	}
+	return gvks, nil
+}
+
+func hasVerbs(verbs []string, required ...string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range verbs {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package resources
+
+import (
+	"sort"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"github.com/christophrj/openmcp-testing/pkg/conditions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+const (
+	builtinRank        = 4
+	customResourceRank = 5
+)
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// kindRanks defines the order in which well-known kinds are created.
+// Other built-in kinds (workloads, services, ...) follow them, custom resources are created last.
+var kindRanks = map[schema.GroupKind]int{
+	{Group: "", Kind: "Namespace"}:                                   0,
+	crdGroupKind:                                                     1,
+	{Group: "", Kind: "ServiceAccount"}:                              2,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        2,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               2,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: 2,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        2,
+	{Group: "", Kind: "ConfigMap"}:                                   3,
+	{Group: "", Kind: "Secret"}:                                      3,
+}
+
+func rank(obj k8s.Object) int {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if r, ok := kindRanks[gvk.GroupKind()]; ok {
+		return r
+	}
+	if scheme.Scheme.Recognizes(gvk) {
+		return builtinRank
+	}
+	return customResourceRank
+}
+
+func isCRD(obj k8s.Object) bool {
+	return obj.GetObjectKind().GroupVersionKind().GroupKind() == crdGroupKind
+}
+
+// sortByDependencies orders objects so that objects are created before the objects that depend on them.
+// Objects of the same rank keep their relative order.
+func sortByDependencies(objs []k8s.Object) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rank(objs[i]) < rank(objs[j])
+	})
+}
+
+// waitForCRDs waits until each of the passed in CustomResourceDefinitions is established
+func waitForCRDs(cfg *envconf.Config, crds []k8s.Object, opts ...wait.Option) error {
+	for _, crd := range crds {
+		klog.Infof("waiting for CRD %s to be established", crd.GetName())
+		ref := internal.UnstructuredRef(crd.GetName(), "", crd.GetObjectKind().GroupVersionKind())
+		if err := wait.For(conditions.Match(ref, cfg, "Established", corev1.ConditionTrue), opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package resources
+
+import (
+	"github.com/christophrj/openmcp-testing/internal"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+)
+
+// Overlay deep merges the passed in fields into every created object before it is created.
+// Nested maps are merged, all other values replace the existing value and nil values remove the field
+func Overlay(overlay map[string]interface{}) Option {
+	return func(o *options) {
+		if overlay != nil {
+			o.overlays = append(o.overlays, overlay)
+		}
+	}
+}
+
+func applyOverlays(obj k8s.Object, overlays []map[string]interface{}) error {
+	if len(overlays) == 0 {
+		return nil
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		for _, overlay := range overlays {
+			internal.DeepMerge(u.Object, runtime.DeepCopyJSON(overlay))
+		}
+		return nil
+	}
+	u, err := internal.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	for _, overlay := range overlays {
+		internal.DeepMerge(u.Object, runtime.DeepCopyJSON(overlay))
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+}
@@ -2,21 +2,97 @@ package resources
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
 	"github.com/christophrj/openmcp-testing/internal"
+	"github.com/christophrj/openmcp-testing/pkg/templates"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/e2e-framework/klient/decoder"
 	"sigs.k8s.io/e2e-framework/klient/k8s"
 	"sigs.k8s.io/e2e-framework/klient/wait"
-	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 )
 
-// DeleteObject deletes the passed in object if it exists
+// DefaultFieldManager is the field manager used for server-side apply by the import helpers
+const DefaultFieldManager = "openmcp-testing"
+
+// Option configures how objects are created on a cluster
+type Option func(*options)
+
+type options struct {
+	fieldManager string
+	force        bool
+	waitOpts     []wait.Option
+	recursive    bool
+	includes     []string
+	excludes     []string
+	templated    bool
+	templateData interface{}
+	labels       map[string]string
+	annotations  map[string]string
+	waitObserved bool
+	observedOpts []wait.Option
+	failIfExists bool
+	templateOpts []templates.Option
+	overlays     []map[string]interface{}
+}
+
+// ServerSideApply creates objects via server-side apply with the passed in field manager.
+// Objects that already exist are updated to match the manifest instead of being left untouched
+func ServerSideApply(fieldManager string) Option {
+	return func(o *options) {
+		o.fieldManager = fieldManager
+	}
+}
+
+// ForceConflicts lets server-side apply take ownership of fields that are managed by another field manager
+func ForceConflicts() Option {
+	return func(o *options) {
+		o.force = true
+	}
+}
+
+// WaitOptions configures how to wait for objects that other objects depend on,
+// e.g. for CustomResourceDefinitions to become established before their instances are created
+func WaitOptions(opts ...wait.Option) Option {
+	return func(o *options) {
+		o.waitOpts = opts
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// DeleteObject deletes the passed in object if it exists.
+// If the object has not been removed within the passed in wait options, the returned error lists
+// its remaining finalizers, its deletion timestamp and its conditions
 func DeleteObject(ctx context.Context, c *envconf.Config, obj k8s.Object, options ...wait.Option) error {
+	return deleteObject(ctx, c, obj, false, options...)
+}
+
+// DeleteObjects deletes the passed in objects in reverse dependency order, e.g. custom resources before their
+// CustomResourceDefinitions and Namespaces last. If wait options are passed, the removal of each group of objects
+// is awaited before the objects they depend on are deleted. Objects that have not been removed are described like by DeleteObject
+func DeleteObjects(ctx context.Context, c *envconf.Config, list *unstructured.UnstructuredList, options ...wait.Option) error {
+	return deleteObjects(ctx, c, list, false, options...)
+}
+
+func deleteObject(ctx context.Context, c *envconf.Config, obj k8s.Object, force bool, options ...wait.Option) error {
 	err := c.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj)
 	if err != nil {
 		return internal.IgnoreNotFound(err)
@@ -24,65 +100,251 @@ func DeleteObject(ctx context.Context, c *envconf.Config, obj k8s.Object, option
 	if err = c.Client().Resources().Delete(ctx, obj); err != nil {
 		return internal.IgnoreNotFound(err)
 	}
-	if options != nil {
-		return wait.For(conditions.New(c.Client().Resources()).ResourceDeleted(obj), options...)
+	return awaitDeletion(ctx, c, obj, force, options...)
+}
+
+func deleteObjects(ctx context.Context, c *envconf.Config, list *unstructured.UnstructuredList, force bool, options ...wait.Option) error {
+	groups := make([]*unstructured.UnstructuredList, customResourceRank+1)
+	for _, obj := range list.Items {
+		r := rank(&obj)
+		if groups[r] == nil {
+			groups[r] = &unstructured.UnstructuredList{}
+		}
+		groups[r].Items = append(groups[r].Items, obj)
+	}
+	for r := customResourceRank; r >= 0; r-- {
+		group := groups[r]
+		if group == nil {
+			continue
+		}
+		for i := range group.Items {
+			obj := &group.Items[i]
+			klog.Infof("deleting object (%s) %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+			if err := c.Client().Resources().Delete(ctx, obj); internal.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+		var errs []error
+		for i := range group.Items {
+			if err := awaitDeletion(ctx, c, &group.Items[i], force, options...); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 // CreateObjectsFromTemplateFile creates objects by first applying the passed data to a template file on the file system
-func CreateObjectsFromTemplateFile(ctx context.Context, cfg *envconf.Config, filePath string, data interface{}) (*unstructured.UnstructuredList, error) {
-	manifest, err := internal.ExecTemplateFile(filePath, data)
+func CreateObjectsFromTemplateFile(ctx context.Context, cfg *envconf.Config, filePath string, data interface{}, opts ...Option) (*unstructured.UnstructuredList, error) {
+	o := newOptions(opts)
+	manifest, err := templates.ExecFile(filePath, data, o.templateOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return createObjectsFromManifest(ctx, cfg, manifest, o)
+}
+
+// CreateObjectsFromTemplate creates all objects of a multi-document template by first applying the passed in data to it.
+// The objects are created in dependency order and returned in the order of their creation.
+// Creation fails if one of the objects already exists, unless ServerSideApply is used
+func CreateObjectsFromTemplate(ctx context.Context, cfg *envconf.Config, template string, data interface{}, opts ...Option) (*unstructured.UnstructuredList, error) {
+	o := newOptions(opts)
+	o.failIfExists = true
+	manifest, err := templates.Exec(template, data, o.templateOpts...)
 	if err != nil {
 		return nil, err
 	}
-	return createObjectsFromManifest(ctx, cfg, manifest)
+	return createObjectsFromManifest(ctx, cfg, manifest, o)
 }
 
-// CreateObjectFromTemplate creates a single object by first applying the passed in data to a template
-func CreateObjectFromTemplate(ctx context.Context, cfg *envconf.Config, template string, data interface{}) (*unstructured.Unstructured, error) {
-	manifest, err := internal.ExecTemplate(template, data)
+// CreateObjectFromTemplate creates the objects of a template like CreateObjectsFromTemplate
+// and returns the object specified by the first document of the template
+func CreateObjectFromTemplate(ctx context.Context, cfg *envconf.Config, template string, data interface{}, opts ...Option) (*unstructured.Unstructured, error) {
+	o := newOptions(opts)
+	o.failIfExists = true
+	manifest, err := templates.Exec(template, data, o.templateOpts...)
 	if err != nil {
 		return nil, err
 	}
-	obj := &unstructured.Unstructured{}
-	err = decoder.DecodeString(manifest, obj, decoder.MutateNamespace(cfg.Namespace()))
+	objs, err := decoder.DecodeAll(ctx, strings.NewReader(manifest), decoder.MutateNamespace(cfg.Namespace()))
 	if err != nil {
 		return nil, err
 	}
-	err = cfg.Client().Resources().Create(ctx, obj)
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("template does not contain any object")
+	}
+	first := objs[0]
+	list, err := createObjects(ctx, cfg, objs, o)
 	if err != nil {
 		return nil, err
 	}
-	return obj, nil
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if obj.GroupVersionKind() == first.GetObjectKind().GroupVersionKind() &&
+			obj.GetNamespace() == first.GetNamespace() && obj.GetName() == first.GetName() {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("object %s/%s has not been created", first.GetNamespace(), first.GetName())
 }
 
-func createObjectsFromManifest(ctx context.Context, cfg *envconf.Config, manifest string) (*unstructured.UnstructuredList, error) {
-	r := strings.NewReader(manifest)
-	list := &unstructured.UnstructuredList{}
-	err := decoder.DecodeEach(ctx, r,
-		func(ctx context.Context, obj k8s.Object) error {
-			return createAndPopulateList(ctx, obj, list, cfg)
-		}, decoder.MutateNamespace(cfg.Namespace()))
-	return list, err
+func createObjectsFromManifest(ctx context.Context, cfg *envconf.Config, manifest string, o *options) (*unstructured.UnstructuredList, error) {
+	objs, err := decoder.DecodeAll(ctx, strings.NewReader(manifest), decoder.MutateNamespace(cfg.Namespace()))
+	if err != nil {
+		return nil, err
+	}
+	return createObjects(ctx, cfg, objs, o)
+}
+
+// CreateObjectsFromDir creates objects specified by the files of a directory on the file system.
+// Objects are created in dependency order, e.g. Namespaces and CustomResourceDefinitions before the objects that need them.
+// Instances of custom resources are only created once all CustomResourceDefinitions of the directory are established
+func CreateObjectsFromDir(ctx context.Context, cfg *envconf.Config, dir string, opts ...Option) (*unstructured.UnstructuredList, error) {
+	return CreateObjectsFromFS(ctx, cfg, os.DirFS(dir), opts...)
 }
 
-// CreateObjectsFromDir creates objects specified by a file on the file system
-func CreateObjectsFromDir(ctx context.Context, cfg *envconf.Config, dir string) (*unstructured.UnstructuredList, error) {
+// CreateObjectsFromFS creates objects specified by the files of an arbitrary file system, e.g. an embed.FS.
+// Only files matching the include patterns are imported, see Include, Exclude and Recursive
+func CreateObjectsFromFS(ctx context.Context, cfg *envconf.Config, fsys fs.FS, opts ...Option) (*unstructured.UnstructuredList, error) {
+	o := newOptions(opts)
+	objs, err := decodeFS(ctx, fsys, o, decoder.MutateNamespace(cfg.Namespace()))
+	if err != nil {
+		return nil, err
+	}
+	return createObjects(ctx, cfg, objs, o)
+}
+
+func createObjects(ctx context.Context, cfg *envconf.Config, objs []k8s.Object, o *options) (*unstructured.UnstructuredList, error) {
+	sortByDependencies(objs)
 	list := &unstructured.UnstructuredList{}
-	err := decoder.DecodeEachFile(ctx, os.DirFS(dir), "*",
-		func(ctx context.Context, obj k8s.Object) error {
-			return createAndPopulateList(ctx, obj, list, cfg)
-		}, decoder.MutateNamespace(cfg.Namespace()))
-	return list, err
+	var crds []k8s.Object
+	for _, obj := range objs {
+		if len(crds) > 0 && rank(obj) == customResourceRank {
+			if err := waitForCRDs(cfg, crds, o.waitOpts...); err != nil {
+				return list, err
+			}
+			crds = nil
+		}
+		if err := createAndPopulateList(ctx, obj, list, cfg, o); err != nil {
+			return list, err
+		}
+		if isCRD(obj) {
+			crds = append(crds, obj)
+		}
+	}
+	return list, nil
 }
 
-func createAndPopulateList(ctx context.Context, obj k8s.Object, list *unstructured.UnstructuredList, cfg *envconf.Config) error {
-	u, err := internal.ToUnstructured(obj)
+func createAndPopulateList(ctx context.Context, obj k8s.Object, list *unstructured.UnstructuredList, cfg *envconf.Config, o *options) error {
+	if err := applyOverlays(obj, o.overlays); err != nil {
+		return err
+	}
+	stampObject(ctx, obj, o)
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	var u *unstructured.Unstructured
+	var created bool
+	var err error
+	switch {
+	case o.fieldManager != "":
+		klog.Infof("applying object (%s) %s/%s", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		u, created, err = applyObject(ctx, cfg, obj, o)
+	case o.failIfExists:
+		klog.Infof("creating object (%s) %s/%s", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		labelRunID(obj)
+		if err = cfg.Client().Resources().Create(ctx, obj); err == nil {
+			u, err = internal.ToUnstructured(obj)
+			created = true
+		}
+	default:
+		klog.Infof("creating object (%s) %s/%s", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		u, created, err = createIgnoreAlreadyExists(ctx, cfg, obj)
+	}
 	if err != nil {
 		return err
 	}
+	// typed objects may have lost their kind while decoding the response of the server
+	u.SetGroupVersionKind(gvk)
 	list.Items = append(list.Items, *u)
-	klog.Infof("creating object (%s) %s/%s", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
-	return decoder.CreateIgnoreAlreadyExists(cfg.Client().Resources())(ctx, obj)
+	// objects that existed before are left to their owner, e.g. CRDs installed by a provider or the default namespace
+	if created {
+		track(ctx, cfg, u)
+	}
+	return nil
+}
+
+// applyObject applies the object server-side and returns its state after the apply and whether it has been created by it.
+// Only created objects are labeled with the run ID, existing objects keep the label if they have been created by this run before
+func applyObject(ctx context.Context, cfg *envconf.Config, obj k8s.Object, o *options) (*unstructured.Unstructured, bool, error) {
+	existing, err := getObject(ctx, cfg, obj)
+	if internal.IgnoreNotFound(err) != nil {
+		return nil, false, err
+	}
+	created := apierrors.IsNotFound(err)
+	if created || existing.GetLabels()[RunIDLabel] == RunID() {
+		labelRunID(obj)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	patch := k8s.Patch{PatchType: types.ApplyPatchType, Data: data}
+	err = cfg.Client().Resources().Patch(ctx, obj, patch, func(po *metav1.PatchOptions) {
+		po.FieldManager = o.fieldManager
+		po.Force = &o.force
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	u, err := internal.ToUnstructured(obj)
+	return u, created, err
+}
+
+// createIgnoreAlreadyExists creates the object unless it exists and returns its state and whether it has been created.
+// An existing object is returned as it is on the cluster
+func createIgnoreAlreadyExists(ctx context.Context, cfg *envconf.Config, obj k8s.Object) (*unstructured.Unstructured, bool, error) {
+	labelRunID(obj)
+	err := cfg.Client().Resources().Create(ctx, obj)
+	if apierrors.IsAlreadyExists(err) {
+		klog.Infof("object (%s) %s/%s already exists", obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		existing, err := getObject(ctx, cfg, obj)
+		return existing, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	u, err := internal.ToUnstructured(obj)
+	return u, true, err
+}
+
+// getObject returns the current state of the object on the cluster
+func getObject(ctx context.Context, cfg *envconf.Config, obj k8s.Object) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := cfg.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// EnsureNamespace creates the namespace if it does not exist yet.
+// A namespace that is created is labeled with the run ID and recorded by the tracker of the context,
+// an existing namespace is left untouched
+func EnsureNamespace(ctx context.Context, cfg *envconf.Config, name string) error {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	stampObject(ctx, ns, newOptions(nil))
+	labelRunID(ns)
+	err := cfg.Client().Resources().Create(ctx, ns)
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create namespace %s: %w", name, err)
+	}
+	klog.Infof("created namespace %s", name)
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	track(ctx, cfg, ns)
+	return nil
 }
@@ -0,0 +1,25 @@
+package resources
+
+import (
+	"os"
+
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// RunIDEnv is the environment variable that can be used to set the run ID of a test run
+const RunIDEnv = "OPENMCP_TESTING_RUN_ID"
+
+var runID = initRunID()
+
+func initRunID() string {
+	if id := os.Getenv(RunIDEnv); id != "" {
+		return id
+	}
+	return envconf.RandomName("run", 12)
+}
+
+// RunID returns the ID that identifies the current test run.
+// It is randomly generated once per process unless set via RunIDEnv
+func RunID() string {
+	return runID
+}
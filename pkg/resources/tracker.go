@@ -0,0 +1,162 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/christophrj/openmcp-testing/internal"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+)
+
+// Tracker records the objects that are created through this library on any cluster,
+// so that they can be deleted at the end of a feature. Objects that existed before are not recorded
+type Tracker struct {
+	mu      sync.Mutex
+	objects []trackedObject
+}
+
+type trackedObject struct {
+	cfg *envconf.Config
+	obj *unstructured.Unstructured
+}
+
+type trackerContextKey struct{}
+
+// NewTracker returns a tracker without any recorded objects
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// WithTracker returns a context with the tracker attached.
+// Objects that are created with the returned context are recorded by the tracker
+func WithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerContextKey{}, tracker)
+}
+
+// TrackerFromContext returns the tracker attached to the context or nil if there is none
+func TrackerFromContext(ctx context.Context) *Tracker {
+	tracker, _ := ctx.Value(trackerContextKey{}).(*Tracker)
+	return tracker
+}
+
+// track records the object if a tracker is attached to the context
+func track(ctx context.Context, cfg *envconf.Config, obj k8s.Object) {
+	tracker := TrackerFromContext(ctx)
+	if tracker == nil {
+		return
+	}
+	u, err := internal.ToUnstructured(obj)
+	if err != nil {
+		klog.Errorf("failed to track object %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		return
+	}
+	ref := internal.UnstructuredRef(u.GetName(), u.GetNamespace(), u.GroupVersionKind())
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.objects = append(tracker.objects, trackedObject{cfg: cfg, obj: ref})
+}
+
+// DeleteAll deletes all recorded objects in reverse order of their creation and waits until they have been removed.
+// Objects on clusters that are no longer reachable, e.g. the cluster of a deleted MCP, are considered removed.
+// An error is returned for each object that could not be removed
+func (tr *Tracker) DeleteAll(ctx context.Context, opts ...wait.Option) []error {
+	return tr.deleteAll(ctx, false, opts...)
+}
+
+// ForceDeleteAll deletes all recorded objects like DeleteAll, but strips the finalizers of each object
+// that has not been removed within the passed in wait options or ForceDeleteTimeout like ForceDeleteObject
+func (tr *Tracker) ForceDeleteAll(ctx context.Context, opts ...wait.Option) []error {
+	return tr.deleteAll(ctx, true, opts...)
+}
+
+func (tr *Tracker) deleteAll(ctx context.Context, force bool, opts ...wait.Option) []error {
+	tr.mu.Lock()
+	objects := tr.objects
+	tr.objects = nil
+	tr.mu.Unlock()
+	var errs []error
+	for i := len(objects) - 1; i >= 0; i-- {
+		tracked := objects[i]
+		obj := tracked.obj
+		klog.Infof("garbage collect object (%s) %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+		err := deleteObject(ctx, tracked.cfg, obj, force, opts...)
+		if utilnet.IsConnectionRefused(err) {
+			klog.Infof("cluster of object %s/%s is not reachable anymore: %v", obj.GetNamespace(), obj.GetName(), err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete object (%s) %s/%s: %w", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName(), err))
+		}
+	}
+	return errs
+}
+
+// TrackObjects attaches a new tracker to the feature context
+func TrackObjects() features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		return WithTracker(ctx, NewTracker())
+	}
+}
+
+// DeleteTrackedObjects deletes all objects recorded by the tracker of the feature context.
+// Objects that could not be removed are reported as test failures
+func DeleteTrackedObjects(opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		deleteTrackedObjects(ctx, t, false, opts...)
+		return ctx
+	}
+}
+
+// ForceDeleteTrackedObjects deletes all objects recorded by the tracker of the feature context
+// and strips the finalizers of objects that have not been removed within the passed in wait options or ForceDeleteTimeout
+func ForceDeleteTrackedObjects(opts ...wait.Option) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		deleteTrackedObjects(ctx, t, true, opts...)
+		return ctx
+	}
+}
+
+// TrackFeatureObjects attaches a new tracker to the context of each feature when registered with env.BeforeEachFeature
+func TrackFeatureObjects() types.FeatureEnvFunc {
+	return func(ctx context.Context, c *envconf.Config, t *testing.T, f types.Feature) (context.Context, error) {
+		return WithTracker(ctx, NewTracker()), nil
+	}
+}
+
+// DeleteFeatureObjects deletes all objects recorded by the tracker of each feature when registered with env.AfterEachFeature.
+// Objects that could not be removed are reported as test failures
+func DeleteFeatureObjects(opts ...wait.Option) types.FeatureEnvFunc {
+	return func(ctx context.Context, c *envconf.Config, t *testing.T, f types.Feature) (context.Context, error) {
+		deleteTrackedObjects(ctx, t, false, opts...)
+		return ctx, nil
+	}
+}
+
+// ForceDeleteFeatureObjects deletes all objects recorded by the tracker of each feature like DeleteFeatureObjects
+// and strips the finalizers of objects that have not been removed within the passed in wait options or ForceDeleteTimeout
+func ForceDeleteFeatureObjects(opts ...wait.Option) types.FeatureEnvFunc {
+	return func(ctx context.Context, c *envconf.Config, t *testing.T, f types.Feature) (context.Context, error) {
+		deleteTrackedObjects(ctx, t, true, opts...)
+		return ctx, nil
+	}
+}
+
+func deleteTrackedObjects(ctx context.Context, t *testing.T, force bool, opts ...wait.Option) {
+	tracker := TrackerFromContext(ctx)
+	if tracker == nil {
+		klog.Info("no tracker attached to the context, nothing to clean up")
+		return
+	}
+	for _, err := range tracker.deleteAll(ctx, force, opts...) {
+		t.Error(err)
+	}
+}
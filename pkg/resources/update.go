@@ -0,0 +1,75 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/christophrj/openmcp-testing/pkg/conditions"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// WaitForObservedGeneration waits after a modification until the controller of the object
+// reports the new generation of the object in status.observedGeneration
+func WaitForObservedGeneration(opts ...wait.Option) Option {
+	return func(o *options) {
+		o.waitObserved = true
+		o.observedOpts = opts
+	}
+}
+
+// Update updates the passed in object on the cluster
+func Update(ctx context.Context, cfg *envconf.Config, obj k8s.Object, opts ...Option) error {
+	klog.Infof("updating %s", fmtObj(obj))
+	if err := cfg.Client().Resources().Update(ctx, obj); err != nil {
+		return err
+	}
+	return waitForObservedGeneration(cfg, obj, newOptions(opts))
+}
+
+// MergePatch applies a JSON merge patch (RFC 7386) to the passed in object
+func MergePatch(ctx context.Context, cfg *envconf.Config, obj k8s.Object, patch []byte, opts ...Option) error {
+	return patchObject(ctx, cfg, obj, k8s.Patch{PatchType: types.MergePatchType, Data: patch}, newOptions(opts))
+}
+
+// JSONPatch applies a JSON patch (RFC 6902) to the passed in object
+func JSONPatch(ctx context.Context, cfg *envconf.Config, obj k8s.Object, patch []byte, opts ...Option) error {
+	return patchObject(ctx, cfg, obj, k8s.Patch{PatchType: types.JSONPatchType, Data: patch}, newOptions(opts))
+}
+
+// Mutate retrieves the latest state of the object, passes it to the mutate function and updates the object.
+// If the update fails due to a conflict, the object is retrieved again and the mutation is retried
+func Mutate(ctx context.Context, cfg *envconf.Config, obj k8s.Object, mutate func(obj k8s.Object) error, opts ...Option) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := cfg.Client().Resources().Get(ctx, obj.GetName(), obj.GetNamespace(), obj); err != nil {
+			return err
+		}
+		if err := mutate(obj); err != nil {
+			return err
+		}
+		klog.Infof("updating %s", fmtObj(obj))
+		return cfg.Client().Resources().Update(ctx, obj)
+	})
+	if err != nil {
+		return err
+	}
+	return waitForObservedGeneration(cfg, obj, newOptions(opts))
+}
+
+func patchObject(ctx context.Context, cfg *envconf.Config, obj k8s.Object, patch k8s.Patch, o *options) error {
+	klog.Infof("patching %s", fmtObj(obj))
+	if err := cfg.Client().Resources().Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	return waitForObservedGeneration(cfg, obj, o)
+}
+
+func waitForObservedGeneration(cfg *envconf.Config, obj k8s.Object, o *options) error {
+	if !o.waitObserved {
+		return nil
+	}
+	return wait.For(conditions.ObservedGeneration(obj, cfg, obj.GetGeneration()), o.observedOpts...)
+}
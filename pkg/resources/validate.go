@@ -0,0 +1,171 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// Validator validates manifests against the OpenAPI schemas of CustomResourceDefinitions without a cluster.
+// It reports unknown fields, wrong types and missing required properties.
+// Unknown fields under metadata are not reported, because the schema of a CustomResourceDefinition does not describe them
+type Validator struct {
+	schemas map[schema.GroupVersionKind]*crdSchema
+}
+
+type crdSchema struct {
+	structural *structuralschema.Structural
+	validator  validation.SchemaValidator
+}
+
+// NewValidator returns a validator without any schemas
+func NewValidator() *Validator {
+	return &Validator{schemas: map[schema.GroupVersionKind]*crdSchema{}}
+}
+
+// AddCRD adds the schemas of all served versions of the CustomResourceDefinition
+func (v *Validator) AddCRD(crd *apiextensionsv1.CustomResourceDefinition) error {
+	for _, version := range crd.Spec.Versions {
+		if !version.Served || version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		internalSchema := &apiextensions.JSONSchemaProps{}
+		if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(version.Schema.OpenAPIV3Schema, internalSchema, nil); err != nil {
+			return fmt.Errorf("failed to convert schema of %s/%s: %w", crd.Name, version.Name, err)
+		}
+		structural, err := structuralschema.NewStructural(internalSchema)
+		if err != nil {
+			return fmt.Errorf("schema of %s/%s is not structural: %w", crd.Name, version.Name, err)
+		}
+		validator, _, err := validation.NewSchemaValidator(internalSchema)
+		if err != nil {
+			return fmt.Errorf("failed to create validator for %s/%s: %w", crd.Name, version.Name, err)
+		}
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+		v.schemas[gvk] = &crdSchema{structural: structural, validator: validator}
+	}
+	return nil
+}
+
+// AddCRDsFromDir adds the CustomResourceDefinitions specified by the files of a directory.
+// Objects of other kinds are ignored, see CreateObjectsFromFS for the options to select files
+func (v *Validator) AddCRDsFromDir(dir string, opts ...Option) error {
+	return v.AddCRDsFromFS(os.DirFS(dir), opts...)
+}
+
+// AddCRDsFromFS adds the CustomResourceDefinitions specified by the files of a file system.
+// Objects of other kinds are ignored, see CreateObjectsFromFS for the options to select files
+func (v *Validator) AddCRDsFromFS(fsys fs.FS, opts ...Option) error {
+	return readFS(fsys, newOptions(opts), func(p string, manifest []byte) error {
+		objs, err := decodeUnstructured(bytes.NewReader(manifest))
+		if err != nil {
+			return fmt.Errorf("failed to decode file %q: %w", p, err)
+		}
+		for _, obj := range objs {
+			if obj.GroupVersionKind().GroupKind() != crdGroupKind {
+				continue
+			}
+			if err := v.addUnstructuredCRD(obj); err != nil {
+				return fmt.Errorf("file %q: %w", p, err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddCRDsFromCluster adds all CustomResourceDefinitions of the cluster
+func (v *Validator) AddCRDsFromCluster(ctx context.Context, cfg *envconf.Config) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinitionList"))
+	if err := cfg.Client().Resources().List(ctx, list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if err := v.addUnstructuredCRD(&list.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Validator) addUnstructuredCRD(obj *unstructured.Unstructured) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, crd); err != nil {
+		return fmt.Errorf("failed to convert CRD %s: %w", obj.GetName(), err)
+	}
+	return v.AddCRD(crd)
+}
+
+// Validate checks the object against the schema of its kind.
+// Built-in kinds are skipped, custom resources without a known schema are reported as error
+func (v *Validator) Validate(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if scheme.Scheme.Recognizes(gvk) || gvk.GroupKind() == crdGroupKind {
+		return nil
+	}
+	s, ok := v.schemas[gvk]
+	if !ok {
+		return fmt.Errorf("%s %s: no schema known for %s", gvk.Kind, obj.GetName(), gvk)
+	}
+	var errs field.ErrorList
+	pruned := pruning.PruneWithOptions(runtime.DeepCopyJSON(obj.Object), s.structural, true, structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true})
+	for _, path := range pruned {
+		errs = append(errs, field.Forbidden(field.NewPath(path), "unknown field"))
+	}
+	errs = append(errs, validation.ValidateCustomResource(nil, obj.Object, s.validator)...)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), errs.ToAggregate())
+	}
+	return nil
+}
+
+// ValidateManifest validates each object of a multi-document manifest
+func (v *Validator) ValidateManifest(manifest string) error {
+	objs, err := decodeUnstructured(strings.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, obj := range objs {
+		errs = append(errs, v.Validate(obj))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateDir validates the objects specified by the files of a directory.
+// Templated files are rendered before they are validated, see Template
+func (v *Validator) ValidateDir(dir string, opts ...Option) error {
+	return v.ValidateFS(os.DirFS(dir), opts...)
+}
+
+// ValidateFS validates the objects specified by the files of a file system.
+// Templated files are rendered before they are validated, see Template
+func (v *Validator) ValidateFS(fsys fs.FS, opts ...Option) error {
+	var errs []error
+	err := readFS(fsys, newOptions(opts), func(p string, manifest []byte) error {
+		if err := v.ValidateManifest(string(manifest)); err != nil {
+			errs = append(errs, fmt.Errorf("file %q: %w", p, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
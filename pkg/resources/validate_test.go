@@ -0,0 +1,76 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const widgetCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required:
+            - size
+            properties:
+              size:
+                type: integer
+              color:
+                type: string
+`
+
+func TestValidator(t *testing.T) {
+	v := NewValidator()
+	if err := v.AddCRDsFromFS(fstest.MapFS{"crd.yaml": {Data: []byte(widgetCRD)}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "valid", spec: "size: 1\n  color: red"},
+		{name: "unknown field", spec: "size: 1\n  shape: round", wantErr: "spec.shape: Forbidden: unknown field"},
+		{name: "wrong type", spec: "size: one", wantErr: "spec.size: Invalid value"},
+		{name: "missing required property", spec: "color: red", wantErr: "spec.size: Required value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: test\nspec:\n  " + tt.spec + "\n"
+			err := v.ValidateManifest(manifest)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatorUnknownKind(t *testing.T) {
+	err := NewValidator().ValidateManifest("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: test\n")
+	if err == nil || !strings.Contains(err.Error(), "no schema known") {
+		t.Fatalf("expected error for unknown kind, got %v", err)
+	}
+}
@@ -56,7 +56,7 @@ func (s *OpenMCPSetup) cleanup() types.EnvFunc {
 	return func(ctx context.Context, c *envconf.Config) (context.Context, error) {
 		klog.Info("cleaning up environment...")
 		for _, sp := range s.ServiceProviders {
-			if err := providers.DeleteServiceProvider(ctx, c, sp.Name, wait.WithTimeout(time.Minute)); err != nil {
+			if err := providers.UninstallServiceProvider(ctx, c, sp, wait.WithTimeout(time.Minute)); err != nil {
 				klog.Errorf("delete service provider failed: %v", err)
 			}
 		}
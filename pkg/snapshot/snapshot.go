@@ -0,0 +1,217 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/christophrj/openmcp-testing/pkg/clusterutils"
+	"github.com/christophrj/openmcp-testing/pkg/resources"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sresources "sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/yaml"
+)
+
+// UpdateFlag is the name of the flag that updates golden files with the captured snapshots.
+// It is namespaced so that it does not collide with an -update flag of the test binary
+const UpdateFlag = "openmcp.update-golden"
+
+var update = flag.Bool(UpdateFlag, false, "update golden files with the captured snapshots")
+
+// generatedNameSuffix replaces the random part of generated names
+const generatedNameSuffix = "<generated>"
+
+// metadataFields are removed from each object because they differ between test runs
+var metadataFields = []string{"uid", "resourceVersion", "generation", "managedFields", "creationTimestamp", "deletionTimestamp", "selfLink"}
+
+// volatileAnnotations are removed from each object because they differ between test runs
+var volatileAnnotations = []string{resources.TestNameAnnotation, resources.FeatureNameAnnotation, "kubectl.kubernetes.io/last-applied-configuration"}
+
+// Selector selects the objects that are captured by a snapshot
+type Selector struct {
+	// Kinds are the kinds of objects to capture
+	Kinds []schema.GroupVersionKind
+	// LabelSelector restricts the captured objects to those matching the selector
+	LabelSelector string
+	// Namespace restricts the captured objects to a namespace, all namespaces are captured if empty
+	Namespace string
+}
+
+// Capture lists the selected objects of the cluster and returns them normalized as multi-document YAML
+func Capture(ctx context.Context, c *envconf.Config, sel Selector) ([]byte, error) {
+	var objs []*unstructured.Unstructured
+	for _, gvk := range sel.Kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.Client().Resources(sel.Namespace).List(ctx, list, k8sresources.WithLabelSelector(sel.LabelSelector)); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", gvk, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			obj.SetGroupVersionKind(gvk)
+			Normalize(obj)
+			objs = append(objs, obj)
+		}
+	}
+	return encode(objs)
+}
+
+// encode returns the objects as multi-document YAML, sorted by their kind, namespace and name.
+// Objects with the same key, e.g. generated names, are ordered by their content to get a stable order
+func encode(objs []*unstructured.Unstructured) ([]byte, error) {
+	type document struct {
+		key  string
+		data []byte
+	}
+	docs := make([]document, 0, len(objs))
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, document{key: sortKey(obj), data: data})
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].key != docs[j].key {
+			return docs[i].key < docs[j].key
+		}
+		return bytes.Compare(docs[i].data, docs[j].data) < 0
+	})
+	b := bytes.Buffer{}
+	for _, doc := range docs {
+		b.WriteString("---\n")
+		b.Write(doc.data)
+	}
+	return b.Bytes(), nil
+}
+
+// Normalize removes all fields of an object that differ between test runs, e.g. uid, resourceVersion,
+// managedFields, timestamps, the run ID label and the random part of generated names
+func Normalize(obj *unstructured.Unstructured) {
+	for _, field := range metadataFields {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	if generateName := obj.GetGenerateName(); generateName != "" && strings.HasPrefix(obj.GetName(), generateName) {
+		obj.SetName(generateName + generatedNameSuffix)
+	}
+	labels := obj.GetLabels()
+	delete(labels, resources.RunIDLabel)
+	obj.SetLabels(nilIfEmpty(labels))
+	annotations := obj.GetAnnotations()
+	for _, annotation := range volatileAnnotations {
+		delete(annotations, annotation)
+	}
+	obj.SetAnnotations(nilIfEmpty(annotations))
+	refs := obj.GetOwnerReferences()
+	for i := range refs {
+		refs[i].UID = ""
+	}
+	obj.SetOwnerReferences(refs)
+	if status, ok := obj.Object["status"]; ok {
+		obj.Object["status"] = removeTimestamps(status)
+	}
+}
+
+// removeTimestamps removes timestamps and observed generations from status fields
+func removeTimestamps(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if strings.HasSuffix(key, "Time") || strings.HasSuffix(key, "Timestamp") || key == "observedGeneration" {
+				delete(value, key)
+				continue
+			}
+			value[key] = removeTimestamps(field)
+		}
+		return value
+	case []interface{}:
+		for i, field := range value {
+			value[i] = removeTimestamps(field)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func nilIfEmpty(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func sortKey(obj *unstructured.Unstructured) string {
+	return strings.Join([]string{obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()}, "/")
+}
+
+// CompareGolden compares the snapshot with the content of the golden file.
+// If the -openmcp.update-golden flag is set, the golden file is overwritten with the snapshot instead
+func CompareGolden(snapshot []byte, goldenFile string) error {
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(goldenFile, snapshot, 0o644)
+	}
+	golden, err := os.ReadFile(goldenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("golden file %s does not exist, run the tests with -%s to create it", goldenFile, UpdateFlag)
+	} else if err != nil {
+		return err
+	}
+	if bytes.Equal(golden, snapshot) {
+		return nil
+	}
+	return fmt.Errorf("snapshot does not match golden file %s (-golden +snapshot):\n%s", goldenFile, cmp.Diff(string(golden), string(snapshot)))
+}
+
+// Assert captures a snapshot of the passed in cluster and compares it against the golden file
+func Assert(ctx context.Context, c *envconf.Config, sel Selector, goldenFile string) error {
+	snapshot, err := Capture(ctx, c, sel)
+	if err != nil {
+		return err
+	}
+	return CompareGolden(snapshot, goldenFile)
+}
+
+// AssertMcpSnapshot captures a snapshot of the MCP cluster and compares it against the golden file
+func AssertMcpSnapshot(sel Selector, goldenFile string) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		c, err := clusterutils.McpConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := Assert(ctx, c, sel, goldenFile); err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}
+}
+
+// AssertOnboardingSnapshot captures a snapshot of the onboarding cluster and compares it against the golden file
+func AssertOnboardingSnapshot(sel Selector, goldenFile string) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		c, err := clusterutils.OnboardingConfig()
+		if err != nil {
+			t.Error(err)
+			return ctx
+		}
+		if err := Assert(ctx, c, sel, goldenFile); err != nil {
+			t.Error(err)
+		}
+		return ctx
+	}
+}
@@ -0,0 +1,224 @@
+package templates
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Option configures how templates are executed
+type Option func(*options)
+
+type options struct {
+	lenient  bool
+	fsys     fs.FS
+	patterns []string
+	funcs    template.FuncMap
+}
+
+// Lenient renders missing map keys as "<no value>" instead of failing the execution
+func Lenient() Option {
+	return func(o *options) {
+		o.lenient = true
+	}
+}
+
+// Includes parses the templates of the file system that match the patterns, so that they can be
+// referenced with {{template "name" .}} or {{include "name" .}}. Files are named by their base name
+func Includes(fsys fs.FS, patterns ...string) Option {
+	return func(o *options) {
+		o.fsys = fsys
+		o.patterns = append(o.patterns, patterns...)
+	}
+}
+
+// Funcs adds custom functions to the templates, overriding helper functions with the same name
+func Funcs(funcs template.FuncMap) Option {
+	return func(o *options) {
+		if o.funcs == nil {
+			o.funcs = template.FuncMap{}
+		}
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+	}
+}
+
+// FuncMap returns the helper functions that are available in every template:
+//
+//	default DEFAULT VALUE   returns DEFAULT if VALUE is empty
+//	required MSG VALUE      fails with MSG if VALUE is empty
+//	toYaml VALUE            marshals VALUE to YAML
+//	indent N TEXT           indents each line of TEXT by N spaces
+//	nindent N TEXT          like indent but starts with a newline
+//	b64enc TEXT, b64dec TEXT encode and decode base64
+//	env NAME                returns the value of an environment variable
+//	requiredEnv NAME        fails if an environment variable is not set
+//	get MAP KEY             returns the value of KEY or nil if MAP does not contain it
+//	hasKey MAP KEY          returns true if MAP contains KEY
+//
+// Additionally, include NAME DATA executes a named template and returns the result, so that it can be piped
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"default":     defaultValue,
+		"required":    required,
+		"toYaml":      toYaml,
+		"indent":      indent,
+		"nindent":     nindent,
+		"b64enc":      b64enc,
+		"b64dec":      b64dec,
+		"env":         os.Getenv,
+		"requiredEnv": requiredEnv,
+		"get":         get,
+		"hasKey":      hasKey,
+	}
+}
+
+// Exec parses and executes textTemplate with the provided data.
+// Missing map keys fail the execution unless Lenient is passed, even if the value is piped to default.
+// Optional keys are looked up with get or index instead, e.g. {{get . "replicas" | default 1}}
+func Exec(textTemplate string, data interface{}, opts ...Option) (string, error) {
+	return exec("template", textTemplate, data, opts...)
+}
+
+// ExecFile parses and executes a template referenced by a file with the provided data
+func ExecFile(filePath string, data interface{}, opts ...Option) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return exec(filepath.Base(filePath), string(content), data, opts...)
+}
+
+// ExecFS parses and executes a template referenced by a file of a file system with the provided data
+func ExecFS(fsys fs.FS, filePath string, data interface{}, opts ...Option) (string, error) {
+	content, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return "", err
+	}
+	return exec(filepath.Base(filePath), string(content), data, opts...)
+}
+
+func exec(name string, textTemplate string, data interface{}, opts ...Option) (string, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	tmpl := template.New(name)
+	funcs := FuncMap()
+	funcs["include"] = func(name string, data interface{}) (string, error) {
+		result := strings.Builder{}
+		err := tmpl.ExecuteTemplate(&result, name, data)
+		return result.String(), err
+	}
+	for fnName, fn := range o.funcs {
+		funcs[fnName] = fn
+	}
+	tmpl.Funcs(funcs)
+	if !o.lenient {
+		tmpl.Option("missingkey=error")
+	}
+	if o.fsys != nil {
+		if _, err := tmpl.ParseFS(o.fsys, o.patterns...); err != nil {
+			return "", fmt.Errorf("failed to parse included templates: %w", err)
+		}
+	}
+	if _, err := tmpl.Parse(textTemplate); err != nil {
+		return "", err
+	}
+	result := strings.Builder{}
+	if err := tmpl.Execute(&result, data); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func required(msg string, value interface{}) (interface{}, error) {
+	if empty(value) {
+		return nil, fmt.Errorf("%s", msg)
+	}
+	return value, nil
+}
+
+func toYaml(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func indent(spaces int, text string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(text, "\n", "\n"+pad)
+}
+
+func nindent(spaces int, text string) string {
+	return "\n" + indent(spaces, text)
+}
+
+func b64enc(text string) string {
+	return base64.StdEncoding.EncodeToString([]byte(text))
+}
+
+func b64dec(text string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(text)
+	return string(data), err
+}
+
+func requiredEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func get(m interface{}, key string) interface{} {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+func hasKey(m interface{}, key string) bool {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	return v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())).IsValid()
+}
@@ -0,0 +1,73 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExec(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "test",
+		"empty":  "",
+		"labels": map[string]string{"app": "web"},
+	}
+	tests := []struct {
+		name     string
+		template string
+		opts     []Option
+		want     string
+		wantErr  string
+	}{
+		{name: "value", template: "{{.name}}", want: "test"},
+		{name: "strict missing key", template: "{{.missing}}", wantErr: "map has no entry for key"},
+		{name: "strict missing key with default", template: `{{.missing | default "x"}}`, wantErr: "map has no entry for key"},
+		{name: "lenient missing key", template: "{{.missing}}", opts: []Option{Lenient()}, want: "<no value>"},
+		{name: "lenient missing key with default", template: `{{.missing | default "x"}}`, opts: []Option{Lenient()}, want: "x"},
+		{name: "default of empty value", template: `{{.empty | default "x"}}`, want: "x"},
+		{name: "default of set value", template: `{{.name | default "x"}}`, want: "test"},
+		{name: "get missing key with default", template: `{{get . "missing" | default "x"}}`, want: "x"},
+		{name: "get set key", template: `{{get .labels "app"}}`, want: "web"},
+		{name: "index missing key with default", template: `{{index . "missing" | default "x"}}`, want: "x"},
+		{name: "hasKey", template: `{{hasKey . "name"}} {{hasKey . "missing"}}`, want: "true false"},
+		{name: "required set value", template: `{{required "name is required" .name}}`, want: "test"},
+		{name: "required empty value", template: `{{required "empty is required" .empty}}`, wantErr: "empty is required"},
+		{name: "toYaml", template: "{{toYaml .labels}}", want: "app: web"},
+		{name: "nindent", template: "labels:{{toYaml .labels | nindent 2}}", want: "labels:\n  app: web"},
+		{name: "b64", template: "{{b64enc .name | b64dec}}", want: "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Exec(tt.template, data, tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"helpers/labels.tpl": {Data: []byte(`{{define "labels"}}app: {{.name}}{{end}}`)},
+		"helpers/other.txt":  {Data: []byte(`{{define "other"}}other{{end}}`)},
+	}
+	got, err := Exec(`metadata:{{include "labels" . | nindent 2}}`, map[string]string{"name": "web"}, Includes(fsys, "helpers/*.tpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "metadata:\n  app: web"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if _, err := Exec(`{{template "other" .}}`, nil, Includes(fsys, "helpers/*.tpl")); err == nil {
+		t.Fatal("expected error for template that does not match the patterns")
+	}
+}